@@ -0,0 +1,77 @@
+package yarninstall
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// berryArchiveIndex indexes a Berry lockfile by locator hash.
+func berryArchiveIndex(lf lockfile.Lockfile) map[string]lockfile.Package {
+	index := map[string]lockfile.Package{}
+	for _, entry := range lf.Packages {
+		if hash := berryLocatorHash(entry.Resolved); hash != "" {
+			index[hash] = entry
+		}
+	}
+
+	return index
+}
+
+// berryArchivePackage returns the lockfile entry of an archive in the cache,
+// e.g. leftpad-npm-0.0.1-787440a011-10c0.zip.
+func berryArchivePackage(name string, index map[string]lockfile.Package, cacheKey string) (lockfile.Package, bool) {
+	segments := strings.Split(strings.TrimSuffix(name, ".zip"), "-")
+	if !strings.HasSuffix(name, ".zip") || len(segments) < 3 {
+		return lockfile.Package{}, false
+	}
+
+	entry, ok := index[segments[len(segments)-2]]
+	if !ok {
+		return lockfile.Package{}, false
+	}
+
+	suffix := segments[len(segments)-1]
+	checksum := entry.Integrity
+	if _, hash, found := strings.Cut(checksum, "/"); found {
+		checksum = hash
+	}
+
+	switch {
+	case checksum == "", suffix == cacheKey:
+		return entry, true
+	case len(checksum) >= 10 && suffix == checksum[:10]:
+		return entry, true
+	default:
+		return lockfile.Package{}, false
+	}
+}
+
+// berryLocatorHash returns the short locator hash Yarn Berry uses in
+// archive names.
+func berryLocatorHash(resolution string) string {
+	if len(resolution) < 2 {
+		return ""
+	}
+
+	i := strings.Index(resolution[1:], "@")
+	if i < 0 {
+		return ""
+	}
+
+	ident, reference := resolution[:i+1], resolution[i+2:]
+
+	var scope, name string
+	if strings.HasPrefix(ident, "@") {
+		scope, name, _ = strings.Cut(ident[1:], "/")
+	} else {
+		name = ident
+	}
+
+	identHash := sha512.Sum512([]byte(scope + name))
+	locatorHash := sha512.Sum512([]byte(hex.EncodeToString(identHash[:]) + reference))
+
+	return hex.EncodeToString(locatorHash[:])[:10]
+}
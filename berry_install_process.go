@@ -1,34 +1,42 @@
 package yarninstall
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
 )
 
 type BerryInstallProcess struct {
 	executable Executable
 	summer     Summer
+	homeDir    string
 	logger     scribe.Emitter
 }
 
-func NewBerryInstallProcess(executable Executable, summer Summer, logger scribe.Emitter) BerryInstallProcess {
+func NewBerryInstallProcess(executable Executable, summer Summer, homeDir string, logger scribe.Emitter) BerryInstallProcess {
 	return BerryInstallProcess{
 		executable: executable,
 		summer:     summer,
+		homeDir:    homeDir,
 		logger:     logger,
 	}
 }
 
 // ShouldRun determines if yarn install should be executed for Berry projects
-func (ip BerryInstallProcess) ShouldRun(workingDir string, metadata map[string]interface{}) (run bool, sha string, err error) {
+func (ip BerryInstallProcess) ShouldRun(workingDir, yarnVersion string, metadata map[string]interface{}) (run bool, sha string, err error) {
 	ip.logger.Subprocess("Process inputs (Berry):")
 
 	// Check for yarn.lock
@@ -51,36 +59,48 @@ func (ip BerryInstallProcess) ShouldRun(workingDir string, metadata map[string]i
 	}
 
 	// Check if using node_modules
-	usesNodeModules := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	usesNodeModules, err := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	if err != nil {
+		return true, "", err
+	}
 	ip.logger.Action("Uses node_modules -> %t", usesNodeModules)
 
 	// If using node_modules, use similar logic to Classic
 	if usesNodeModules {
-		return ip.shouldRunForNodeModules(workingDir, metadata)
+		return ip.shouldRunForNodeModules(workingDir, yarnVersion, yarnrcConfig, metadata)
 	}
 
 	// For PnP projects, check different conditions
-	return ip.shouldRunForPnP(workingDir, yarnrcConfig, metadata)
+	return ip.shouldRunForPnP(workingDir, yarnVersion, yarnrcConfig, metadata)
 }
 
-func (ip BerryInstallProcess) shouldRunForNodeModules(workingDir string, metadata map[string]interface{}) (bool, string, error) {
-	// For node_modules, check if yarn.lock has changed (similar to Classic)
-	buffer := bytes.NewBuffer(nil)
-
-	err := ip.executable.Execute(pexec.Execution{
-		Args:   []string{"info", "--all", "--json"},
-		Stdout: buffer,
-		Stderr: buffer,
-		Dir:    workingDir,
-	})
+func (ip BerryInstallProcess) shouldRunForNodeModules(workingDir, yarnVersion string, config *YarnrcConfig, metadata map[string]interface{}) (bool, string, error) {
+	// The lockfile is parsed rather than queried through 'yarn info' so that
+	// the cache check does not depend on the yarn binary. A lockfile that
+	// cannot be parsed is left for 'yarn install' to report on.
+	lf, err := lockfile.Parse(filepath.Join(workingDir, YarnLock))
 	if err != nil {
-		ip.logger.Action("Failed to get yarn info, falling back to install")
+		ip.logger.Action("Failed to parse yarn.lock, falling back to install")
 		return true, "", nil
 	}
 
+	inputs, err := CacheKeyInputs(workingDir, ip.homeDir, yarnVersion)
+	if err != nil {
+		return true, "", fmt.Errorf("failed to determine cache key inputs: %w", err)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+
 	nodeEnv := os.Getenv("NODE_ENV")
 	buffer.WriteString(nodeEnv)
 
+	err = writeCacheKeyInputs(buffer, inputs)
+	if err != nil {
+		return true, "", err
+	}
+
+	writeLockfileResolutions(buffer, lf)
+
 	file, err := os.CreateTemp("", "berry-config-file")
 	if err != nil {
 		return true, "", fmt.Errorf("failed to create temp file: %w", err)
@@ -92,7 +112,14 @@ func (ip BerryInstallProcess) shouldRunForNodeModules(workingDir string, metadat
 		return true, "", fmt.Errorf("failed to write temp file: %w", err)
 	}
 
-	sum, err := ip.summer.Sum(filepath.Join(workingDir, YarnLock), filepath.Join(workingDir, "package.json"), file.Name())
+	paths := []string{filepath.Join(workingDir, YarnLock), filepath.Join(workingDir, "package.json")}
+
+	_, err = os.Stat(filepath.Join(workingDir, YarnrcYml))
+	if err == nil {
+		paths = append(paths, filepath.Join(workingDir, YarnrcYml))
+	}
+
+	sum, err := ip.summer.Sum(append(paths, file.Name())...)
 	if err != nil {
 		return true, "", fmt.Errorf("unable to sum config files: %w", err)
 	}
@@ -105,7 +132,7 @@ func (ip BerryInstallProcess) shouldRunForNodeModules(workingDir string, metadat
 	return false, "", nil
 }
 
-func (ip BerryInstallProcess) shouldRunForPnP(workingDir string, config *YarnrcConfig, metadata map[string]interface{}) (bool, string, error) {
+func (ip BerryInstallProcess) shouldRunForPnP(workingDir, yarnVersion string, config *YarnrcConfig, metadata map[string]interface{}) (bool, string, error) {
 	// Check for .yarnrc.yml
 	hasYarnrcYml := config != nil
 	ip.logger.Action(".yarnrc.yml -> %t", hasYarnrcYml)
@@ -133,10 +160,111 @@ func (ip BerryInstallProcess) shouldRunForPnP(workingDir string, config *YarnrcC
 		return false, "", nil
 	}
 
-	// Should run install in other cases:
-	// - No local cache
-	// - No .pnp.cjs file
-	return true, "", nil
+	// Otherwise the install can be skipped when none of the inputs to the
+	// Plug'n'Play install changed since the cached layer was built
+	inputs, err := CacheKeyInputs(workingDir, ip.homeDir, yarnVersion)
+	if err != nil {
+		return true, "", fmt.Errorf("failed to determine cache key inputs: %w", err)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err = writeCacheKeyInputs(buffer, inputs)
+	if err != nil {
+		return true, "", err
+	}
+
+	file, err := os.CreateTemp("", "berry-pnp-config-file")
+	if err != nil {
+		return true, "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(buffer.Bytes())
+	if err != nil {
+		return true, "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	paths := []string{filepath.Join(workingDir, YarnLock)}
+	if hasYarnrcYml {
+		paths = append(paths, filepath.Join(workingDir, YarnrcYml))
+	}
+
+	manifestPaths, err := packageJSONPaths(workingDir)
+	if err != nil {
+		return true, "", err
+	}
+
+	sum, err := ip.summer.Sum(append(append(paths, manifestPaths...), file.Name())...)
+	if err != nil {
+		return true, "", fmt.Errorf("unable to sum config files: %w", err)
+	}
+
+	prevSHA, ok := metadata["cache_sha"].(string)
+	if (ok && sum != prevSHA) || !ok {
+		return true, sum, nil
+	}
+
+	return false, "", nil
+}
+
+// CacheKey returns the components of the cache key that ShouldRun hashes, as
+// they are recorded in the metadata of a modules layer.
+func (ip BerryInstallProcess) CacheKey(workingDir, yarnVersion string) (map[string]string, error) {
+	components, err := CacheKeyComponents(workingDir, ip.homeDir, yarnVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	yarnrcConfig, err := ParseYarnrcYml(workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .yarnrc.yml: %w", err)
+	}
+
+	usesNodeModules, err := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Plug'n'Play install does not depend on NODE_ENV
+	if !usesNodeModules {
+		delete(components, "NODE_ENV")
+	}
+
+	return components, nil
+}
+
+// writeLockfileResolutions writes the resolved lockfile entries in a stable order.
+func writeLockfileResolutions(buffer *bytes.Buffer, lf lockfile.Lockfile) {
+	var lines []string
+	for _, entry := range lf.Packages {
+		lines = append(lines, fmt.Sprintf("%s %s %s", entry.Resolved, entry.Integrity, entry.LinkType))
+	}
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		buffer.WriteString(strings.TrimSpace(line) + "\n")
+	}
+}
+
+// packageJSONPaths returns the package.json of the project followed by the
+// package.json of each of its workspaces.
+func packageJSONPaths(workingDir string) ([]string, error) {
+	manifest, err := ParsePackageManifest(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces, err := manifest.WorkspaceDirs(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{filepath.Join(workingDir, "package.json")}
+	for _, workspace := range workspaces {
+		paths = append(paths, filepath.Join(workingDir, workspace, "package.json"))
+	}
+
+	return paths, nil
 }
 
 func (ip BerryInstallProcess) SetupModules(workingDir, currentModulesLayerPath, nextModulesLayerPath string) (string, error) {
@@ -146,7 +274,10 @@ func (ip BerryInstallProcess) SetupModules(workingDir, currentModulesLayerPath,
 		return "", fmt.Errorf("failed to parse .yarnrc.yml: %w", err)
 	}
 
-	usesNodeModules := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	usesNodeModules, err := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	if err != nil {
+		return "", err
+	}
 
 	if usesNodeModules {
 		// Use Classic node_modules setup logic
@@ -161,10 +292,17 @@ func (ip BerryInstallProcess) SetupModules(workingDir, currentModulesLayerPath,
 func (ip BerryInstallProcess) setupNodeModules(workingDir, currentModulesLayerPath, nextModulesLayerPath string) (string, error) {
 	// This mirrors the Classic logic
 	if currentModulesLayerPath != "" {
-		err := fs.Copy(filepath.Join(currentModulesLayerPath, "node_modules"), filepath.Join(nextModulesLayerPath, "node_modules"))
+		var strategy string
+		duration, err := chronos.DefaultClock.Measure(func() error {
+			var err error
+			strategy, err = copyModulesLayer(currentModulesLayerPath, nextModulesLayerPath, false)
+			return err
+		})
 		if err != nil {
-			return "", fmt.Errorf("failed to copy node_modules directory: %w", err)
+			return "", err
 		}
+
+		ip.logger.Subprocess("Copied node_modules from %s (%s) in %s", currentModulesLayerPath, strategy, duration.Round(time.Millisecond))
 	} else {
 		file, err := os.Lstat(filepath.Join(workingDir, "node_modules"))
 		if err != nil {
@@ -199,26 +337,36 @@ func (ip BerryInstallProcess) setupNodeModules(workingDir, currentModulesLayerPa
 	return nextModulesLayerPath, nil
 }
 
-func (ip BerryInstallProcess) Execute(workingDir, modulesLayerPath string, launch bool) error {
+func (ip BerryInstallProcess) Execute(workingDir, modulesLayerPath, cacheLayerPath string, launch bool) error {
 	// Parse configuration to determine installation strategy
 	yarnrcConfig, err := ParseYarnrcYml(workingDir)
 	if err != nil {
 		return fmt.Errorf("failed to parse .yarnrc.yml: %w", err)
 	}
 
-	usesNodeModules := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	usesNodeModules, err := ShouldUseNodeModules(workingDir, yarnrcConfig)
+	if err != nil {
+		return err
+	}
 
 	var installErr error
 	if usesNodeModules {
-		installErr = ip.executeNodeModulesInstall(workingDir, modulesLayerPath, launch, yarnrcConfig)
+		installErr = ip.executeNodeModulesInstall(workingDir, modulesLayerPath, cacheLayerPath, launch, yarnrcConfig)
 	} else {
-		installErr = ip.executePnPInstall(workingDir, modulesLayerPath, launch, yarnrcConfig)
+		installErr = ip.executePnPInstall(workingDir, modulesLayerPath, cacheLayerPath, launch, yarnrcConfig)
 	}
 
 	if installErr != nil {
 		return installErr
 	}
 
+	if cacheLayerPath != "" {
+		err = ip.trimCache(workingDir, filepath.Join(cacheLayerPath, "cache"))
+		if err != nil {
+			return fmt.Errorf("failed to trim yarn cache: %w", err)
+		}
+	}
+
 	// Execute build scripts if BP_NODE_RUN_SCRIPTS is set
 	if buildScripts := os.Getenv("BP_NODE_RUN_SCRIPTS"); buildScripts != "" {
 		return ip.executeRunScripts(workingDir, buildScripts)
@@ -227,28 +375,39 @@ func (ip BerryInstallProcess) Execute(workingDir, modulesLayerPath string, launc
 	return nil
 }
 
-func (ip BerryInstallProcess) executeNodeModulesInstall(workingDir, modulesLayerPath string, launch bool, config *YarnrcConfig) error {
+func (ip BerryInstallProcess) executeNodeModulesInstall(workingDir, modulesLayerPath, cacheLayerPath string, launch bool, config *YarnrcConfig) error {
 	environment := os.Environ()
 	environment = append(environment, fmt.Sprintf("PATH=%s%c%s", os.Getenv("PATH"), os.PathListSeparator, filepath.Join("node_modules", ".bin")))
 
-	// Use --immutable instead of --frozen-lockfile for Berry
-	installArgs := []string{"install"}
+	// The archives are only needed during the install, so they can live in
+	// the global cache in the cache layer
+	if cacheLayerPath != "" {
+		environment = append(environment,
+			fmt.Sprintf("YARN_GLOBAL_FOLDER=%s", cacheLayerPath),
+			"YARN_ENABLE_GLOBAL_CACHE=true",
+		)
+	}
 
-	// Check if immutable installs are disabled
-	if config == nil || config.EnableImmutableInstalls == nil || *config.EnableImmutableInstalls {
-		installArgs = append(installArgs, "--immutable")
+	installArgs, err := ip.installArgs(workingDir, launch, config)
+	if err != nil {
+		return err
 	}
 
-	if !launch {
-		installArgs = append(installArgs, "--production", "false")
+	// Berry has no --modules-folder, so the node_modules directories are
+	// installed in the project and moved into the layer afterwards
+	locations, err := berryNodeModulesLocations(workingDir, modulesLayerPath)
+	if err != nil {
+		return err
 	}
 
-	// For Berry node_modules, set modules folder
-	installArgs = append(installArgs, "--modules-folder", filepath.Join(modulesLayerPath, "node_modules"))
+	locations, err = restoreNodeModules(locations)
+	if err != nil {
+		return err
+	}
 
 	ip.logger.Subprocess("Running 'yarn %s'", strings.Join(installArgs, " "))
 
-	err := ip.executable.Execute(pexec.Execution{
+	err = ip.executable.Execute(pexec.Execution{
 		Args:   installArgs,
 		Env:    environment,
 		Stdout: ip.logger.ActionWriter,
@@ -256,36 +415,270 @@ func (ip BerryInstallProcess) executeNodeModulesInstall(workingDir, modulesLayer
 		Dir:    workingDir,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to execute yarn install: %w", err)
+		return fmt.Errorf("failed to execute yarn %s: %w", installArgs[0], err)
+	}
+
+	return relocateNodeModules(locations)
+}
+
+// copyLayerTree copies the named directory from one layer into another.
+func copyLayerTree(sourceLayer, destinationLayer, name string, allowHardlinks bool) (string, error) {
+	destination := filepath.Join(destinationLayer, name)
+
+	strategy, err := CopyTree(filepath.Join(sourceLayer, name), destination, allowHardlinks)
+	if err != nil {
+		return "", err
+	}
+
+	return strategy, filepath.WalkDir(destination, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.Type()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+
+		if !filepath.IsAbs(target) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourceLayer, target)
+		if err != nil {
+			return err
+		}
+
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+
+		return os.Symlink(filepath.Join(destinationLayer, rel), path)
+	})
+}
+
+// copyModulesLayer copies the root and workspace node_modules of one modules
+// layer into another and returns the copy strategy that was used.
+func copyModulesLayer(sourceLayer, destinationLayer string, allowHardlinks bool) (string, error) {
+	strategy, err := copyLayerTree(sourceLayer, destinationLayer, "node_modules", allowHardlinks)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy node_modules directory: %w", err)
+	}
+
+	// Workspace node_modules are kept next to the root node_modules
+	exists, err := fs.Exists(filepath.Join(sourceLayer, "workspaces"))
+	if err != nil {
+		return "", fmt.Errorf("failed to stat workspaces directory: %w", err)
+	}
+
+	if exists {
+		strategy, err = copyLayerTree(sourceLayer, destinationLayer, "workspaces", allowHardlinks)
+		if err != nil {
+			return "", fmt.Errorf("failed to copy workspaces directory: %w", err)
+		}
+	}
+
+	return strategy, nil
+}
+
+// nodeModulesLocation pairs a node_modules directory with its place in the layer.
+type nodeModulesLocation struct {
+	project string
+	layer   string
+
+	// link is the symlink target the project held before the install, which
+	// is put back once the install output has been moved into the layer
+	link string
+}
+
+func berryNodeModulesLocations(workingDir, modulesLayerPath string) ([]nodeModulesLocation, error) {
+	manifest, err := ParsePackageManifest(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces, err := manifest.WorkspaceDirs(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []nodeModulesLocation{{
+		project: filepath.Join(workingDir, "node_modules"),
+		layer:   filepath.Join(modulesLayerPath, "node_modules"),
+	}}
+
+	for _, workspace := range workspaces {
+		locations = append(locations, nodeModulesLocation{
+			project: filepath.Join(workingDir, workspace, "node_modules"),
+			layer:   filepath.Join(modulesLayerPath, "workspaces", workspace, "node_modules"),
+		})
+	}
+
+	return locations, nil
+}
+
+// restoreNodeModules replaces the node_modules symlinks with the layer contents.
+func restoreNodeModules(locations []nodeModulesLocation) ([]nodeModulesLocation, error) {
+	for i, location := range locations {
+		info, err := os.Lstat(location.project)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to stat node_modules directory: %w", err)
+			}
+		}
+
+		if info != nil && info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		if info != nil {
+			locations[i].link, err = os.Readlink(location.project)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read node_modules symlink: %w", err)
+			}
+
+			err = os.Remove(location.project)
+			if err != nil {
+				return nil, fmt.Errorf("failed to remove node_modules symlink: %w", err)
+			}
+		}
+
+		exists, err := fs.Exists(location.layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat node_modules directory: %w", err)
+		}
+
+		if exists {
+			err = fs.Move(location.layer, location.project)
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore node_modules directory from layer: %w", err)
+			}
+		}
+	}
+
+	return locations, nil
+}
+
+// relocateNodeModules moves the node_modules directories that yarn created in
+// the project into the layer and links them back into the project.
+func relocateNodeModules(locations []nodeModulesLocation) error {
+	for _, location := range locations {
+		info, err := os.Lstat(location.project)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to stat node_modules directory: %w", err)
+		}
+
+		if !info.IsDir() {
+			continue
+		}
+
+		err = absolutizeEscapingSymlinks(location.project)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite node_modules symlinks: %w", err)
+		}
+
+		err = os.MkdirAll(filepath.Dir(location.layer), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create node_modules directory in layer: %w", err)
+		}
+
+		err = fs.Move(location.project, location.layer)
+		if err != nil {
+			return fmt.Errorf("failed to move node_modules directory to layer: %w", err)
+		}
+
+		link := location.link
+		if link == "" {
+			link = location.layer
+		}
+
+		err = os.Symlink(link, location.project)
+		if err != nil {
+			return fmt.Errorf("failed to symlink node_modules into working directory: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (ip BerryInstallProcess) executePnPInstall(workingDir, modulesLayerPath string, launch bool, config *YarnrcConfig) error {
+// absolutizeEscapingSymlinks makes symlinks that leave dir absolute.
+func absolutizeEscapingSymlinks(root string) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.Type()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+
+		if filepath.IsAbs(target) {
+			return nil
+		}
+
+		resolved := filepath.Join(filepath.Dir(path), target)
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil {
+			return err
+		}
+
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+
+		return os.Symlink(resolved, path)
+	})
+}
+
+func (ip BerryInstallProcess) executePnPInstall(workingDir, modulesLayerPath, cacheLayerPath string, launch bool, config *YarnrcConfig) error {
 	environment := os.Environ()
 
 	// Set up cache folder to point to layer
 	cacheDir := filepath.Join(modulesLayerPath, "cache")
 	environment = append(environment, fmt.Sprintf("YARN_CACHE_FOLDER=%s", cacheDir))
 
+	// The PnP runtime loads packages from the archives, so they have to stay
+	// in the modules layer. The cache layer serves as Yarn's mirror, from
+	// which archives are copied instead of being downloaded again.
+	if cacheLayerPath != "" {
+		environment = append(environment,
+			fmt.Sprintf("YARN_GLOBAL_FOLDER=%s", cacheLayerPath),
+			"YARN_ENABLE_GLOBAL_CACHE=false",
+			"YARN_ENABLE_MIRROR=true",
+		)
+	}
+
 	// Ensure cache directory exists
 	err := os.MkdirAll(cacheDir, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	installArgs := []string{"install"}
-
-	// Check if immutable installs are disabled
-	if config == nil || config.EnableImmutableInstalls == nil || *config.EnableImmutableInstalls {
-		installArgs = append(installArgs, "--immutable")
-	}
-
-	if !launch {
-		// For Berry, we might not need --production for PnP since dependencies are resolved differently
-		// But keeping for compatibility
-		installArgs = append(installArgs, "--production", "false")
+	installArgs, err := ip.installArgs(workingDir, launch, config)
+	if err != nil {
+		return err
 	}
 
 	ip.logger.Subprocess("Running 'yarn %s' (PnP)", strings.Join(installArgs, " "))
@@ -298,7 +691,178 @@ func (ip BerryInstallProcess) executePnPInstall(workingDir, modulesLayerPath str
 		Dir:    workingDir,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to execute yarn install (PnP): %w", err)
+		return fmt.Errorf("failed to execute yarn %s (PnP): %w", installArgs[0], err)
+	}
+
+	err = savePnPArtifacts(workingDir, modulesLayerPath)
+	if err != nil {
+		return fmt.Errorf("failed to save PnP artifacts to layer: %w", err)
+	}
+
+	return nil
+}
+
+// pnpArtifacts are the files a Plug'n'Play install writes into the project.
+var pnpArtifacts = []string{PnpCjs, PnpLoaderMjs, PnpDataJson, YarnUnplugged, YarnInstallState}
+
+func savePnPArtifacts(workingDir, modulesLayerPath string) error {
+	for _, artifact := range pnpArtifacts {
+		source := filepath.Join(workingDir, filepath.FromSlash(artifact))
+
+		exists, err := fs.Exists(source)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			continue
+		}
+
+		destination := filepath.Join(modulesLayerPath, "pnp", filepath.FromSlash(artifact))
+		err = os.MkdirAll(filepath.Dir(destination), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = fs.Copy(source, destination)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestorePnPArtifacts copies the Plug'n'Play artifacts saved in a cached
+// layer back into the project, replacing any that are already present.
+func RestorePnPArtifacts(workingDir, modulesLayerPath string) error {
+	for _, artifact := range pnpArtifacts {
+		source := filepath.Join(modulesLayerPath, "pnp", filepath.FromSlash(artifact))
+
+		exists, err := fs.Exists(source)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			continue
+		}
+
+		destination := filepath.Join(workingDir, filepath.FromSlash(artifact))
+		err = os.RemoveAll(destination)
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(filepath.Dir(destination), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = fs.Copy(source, destination)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// installArgs returns the arguments of the yarn command that populates a layer.
+func (ip BerryInstallProcess) installArgs(workingDir string, launch bool, config *YarnrcConfig) ([]string, error) {
+	if launch {
+		available, err := ip.hasWorkspaceTools(workingDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if !available {
+			return nil, fmt.Errorf("cannot remove devDependencies from the launch layer: 'yarn workspaces focus' requires the %s plugin, add it with 'yarn plugin import workspace-tools' or upgrade to Yarn 4", WorkspaceToolsPlugin)
+		}
+
+		return []string{"workspaces", "focus", "--all", "--production"}, nil
+	}
+
+	installArgs := []string{"install"}
+
+	// Use --immutable instead of --frozen-lockfile for Berry unless immutable
+	// installs are disabled
+	if config == nil || config.EnableImmutableInstalls == nil || *config.EnableImmutableInstalls {
+		installArgs = append(installArgs, "--immutable")
+	}
+
+	return installArgs, nil
+}
+
+// hasWorkspaceTools reports whether the workspace-tools plugin is loaded.
+func (ip BerryInstallProcess) hasWorkspaceTools(workingDir string) (bool, error) {
+	buffer := bytes.NewBuffer(nil)
+	err := ip.executable.Execute(pexec.Execution{
+		Args:   []string{"plugin", "runtime", "--json"},
+		Stdout: buffer,
+		Stderr: buffer,
+		Dir:    workingDir,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to execute yarn plugin runtime: %w\n%s", err, buffer.String())
+	}
+
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		var plugin struct {
+			Name string `json:"name"`
+		}
+
+		if json.Unmarshal(scanner.Bytes(), &plugin) != nil {
+			continue
+		}
+
+		if plugin.Name == WorkspaceToolsPlugin {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// trimCache removes the archives that yarn.lock no longer refers to.
+func (ip BerryInstallProcess) trimCache(workingDir, cacheDir string) error {
+	lf, err := lockfile.Parse(filepath.Join(workingDir, YarnLock))
+	if err != nil {
+		ip.logger.Subprocess("Failed to parse yarn.lock, skipping cache trimming")
+		return nil
+	}
+
+	index := berryArchiveIndex(lf)
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var removed int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".zip") {
+			continue
+		}
+
+		if _, ok := berryArchivePackage(name, index, lf.Metadata.CacheKey); ok {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(cacheDir, name))
+		if err != nil {
+			return err
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		ip.logger.Subprocess("Removed %d unused archive(s) from the yarn cache", removed)
 	}
 
 	return nil
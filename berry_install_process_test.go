@@ -0,0 +1,692 @@
+package yarninstall_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/paketo-buildpacks/yarn-install/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBerryInstallProcess(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ShouldRun", func() {
+		var (
+			workingDir     string
+			homeDir        string
+			executable     *fakes.Executable
+			summer         *fakes.Summer
+			buffer         *bytes.Buffer
+			installProcess yarninstall.BerryInstallProcess
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			homeDir, err = os.MkdirTemp("", "home")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: node-modules\n"), 0600)).To(Succeed())
+
+			executable = &fakes.Executable{}
+			summer = &fakes.Summer{}
+			summer.SumCall.Returns.String = "some-other-sha"
+			buffer = bytes.NewBuffer(nil)
+
+			installProcess = yarninstall.NewBerryInstallProcess(executable, summer, homeDir, scribe.NewEmitter(buffer))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(homeDir)).To(Succeed())
+		})
+
+		context("when there is no yarn.lock file", func() {
+			it("runs the install", func() {
+				run, sha, err := installProcess.ShouldRun(workingDir, "4.1.0", map[string]interface{}{
+					"cache_sha": "some-sha",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(run).To(BeTrue())
+				Expect(sha).To(Equal(""))
+				Expect(buffer.String()).To(ContainSubstring("yarn.lock -> Not found"))
+			})
+		})
+
+		context("when the project uses the node-modules linker", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("__metadata:\n  version: 8\n  cacheKey: 10c0\n"), 0600)).To(Succeed())
+			})
+
+			it("sums the lockfile, configuration and yarn version without invoking yarn", func() {
+				run, sha, err := installProcess.ShouldRun(workingDir, "4.1.0", map[string]interface{}{
+					"cache_sha": "some-sha",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(run).To(BeTrue())
+				Expect(sha).To(Equal("some-other-sha"))
+
+				Expect(executable.ExecuteCall.CallCount).To(Equal(0))
+
+				Expect(summer.SumCall.Receives.Paths).To(HaveLen(4))
+				Expect(summer.SumCall.Receives.Paths[0]).To(Equal(filepath.Join(workingDir, "yarn.lock")))
+				Expect(summer.SumCall.Receives.Paths[1]).To(Equal(filepath.Join(workingDir, "package.json")))
+				Expect(summer.SumCall.Receives.Paths[2]).To(Equal(filepath.Join(workingDir, ".yarnrc.yml")))
+				Expect(summer.SumCall.Receives.Paths[3]).To(ContainSubstring("berry-config-file"))
+
+				content, err := os.ReadFile(summer.SumCall.Receives.Paths[3])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring("yarn-version=4.1.0\n"))
+			})
+
+			context("when the sum matches the previous build", func() {
+				it("does not run the install", func() {
+					run, sha, err := installProcess.ShouldRun(workingDir, "4.1.0", map[string]interface{}{
+						"cache_sha": "some-other-sha",
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(run).To(BeFalse())
+					Expect(sha).To(Equal(""))
+				})
+			})
+
+			context("when the home directory holds registry configuration", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(homeDir, ".yarnrc.yml"), []byte("npmAuthToken: some-secret-token\n"), 0600)).To(Succeed())
+				})
+
+				it("includes the checksum of the configuration in the summed configuration", func() {
+					_, _, err := installProcess.ShouldRun(workingDir, "4.1.0", map[string]interface{}{})
+					Expect(err).NotTo(HaveOccurred())
+
+					content, err := os.ReadFile(summer.SumCall.Receives.Paths[3])
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(MatchRegexp(`~/\.yarnrc\.yml=sha256:[0-9a-f]{64}\n`))
+					Expect(string(content)).NotTo(ContainSubstring("some-secret-token"))
+				})
+			})
+
+			context("when the lockfile resolves packages", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(`__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"leftpad@npm:^0.0.1":
+  version: 0.0.1
+  resolution: "leftpad@npm:0.0.1"
+  checksum: 10c0/some-checksum
+  languageName: node
+  linkType: hard
+`), 0600)).To(Succeed())
+				})
+
+				it("includes the resolved entries in the summed configuration", func() {
+					_, _, err := installProcess.ShouldRun(workingDir, "4.1.0", map[string]interface{}{})
+					Expect(err).NotTo(HaveOccurred())
+
+					content, err := os.ReadFile(summer.SumCall.Receives.Paths[3])
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(ContainSubstring("leftpad@npm:0.0.1 10c0/some-checksum hard\n"))
+				})
+			})
+
+			context("when the lockfile cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("__metadata: [\n"), 0600)).To(Succeed())
+				})
+
+				it("falls back to running the install", func() {
+					run, sha, err := installProcess.ShouldRun(workingDir, "4.1.0", map[string]interface{}{
+						"cache_sha": "some-sha",
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(run).To(BeTrue())
+					Expect(sha).To(Equal(""))
+					Expect(summer.SumCall.CallCount).To(Equal(0))
+					Expect(buffer.String()).To(ContainSubstring("Failed to parse yarn.lock, falling back to install"))
+				})
+			})
+		})
+
+		context("when the project uses Plug'n'Play", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("__metadata:\n  version: 8\n  cacheKey: 10c0\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"workspaces": ["packages/*"]}`), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "a"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "packages", "a", "package.json"), []byte(`{}`), 0600)).To(Succeed())
+			})
+
+			it("sums the lockfile, configuration, manifests and yarn version", func() {
+				run, sha, err := installProcess.ShouldRun(workingDir, "4.0.2", map[string]interface{}{
+					"cache_sha": "some-sha",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(run).To(BeTrue())
+				Expect(sha).To(Equal("some-other-sha"))
+
+				Expect(executable.ExecuteCall.CallCount).To(Equal(0))
+
+				Expect(summer.SumCall.Receives.Paths).To(HaveLen(5))
+				Expect(summer.SumCall.Receives.Paths[0]).To(Equal(filepath.Join(workingDir, "yarn.lock")))
+				Expect(summer.SumCall.Receives.Paths[1]).To(Equal(filepath.Join(workingDir, ".yarnrc.yml")))
+				Expect(summer.SumCall.Receives.Paths[2]).To(Equal(filepath.Join(workingDir, "package.json")))
+				Expect(summer.SumCall.Receives.Paths[3]).To(Equal(filepath.Join(workingDir, "packages", "a", "package.json")))
+				Expect(summer.SumCall.Receives.Paths[4]).To(ContainSubstring("berry-pnp-config-file"))
+
+				content, err := os.ReadFile(summer.SumCall.Receives.Paths[4])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring("yarn-version=4.0.2\n"))
+				Expect(string(content)).To(MatchRegexp(`packages/a/package.json=sha256:[0-9a-f]{64}\n`))
+			})
+
+			context("when the sum matches the previous build", func() {
+				it("does not run the install", func() {
+					run, sha, err := installProcess.ShouldRun(workingDir, "4.0.2", map[string]interface{}{
+						"cache_sha": "some-other-sha",
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(run).To(BeFalse())
+					Expect(sha).To(Equal(""))
+				})
+			})
+
+			context("when the project is a zero-install project", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".pnp.cjs"), []byte{}, 0600)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, ".yarn", "cache"), os.ModePerm)).To(Succeed())
+				})
+
+				it("skips the install without invoking yarn", func() {
+					run, _, err := installProcess.ShouldRun(workingDir, "4.0.2", map[string]interface{}{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(run).To(BeFalse())
+					Expect(executable.ExecuteCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+	})
+
+	context("CacheKey", func() {
+		var (
+			workingDir     string
+			homeDir        string
+			executable     *fakes.Executable
+			installProcess yarninstall.BerryInstallProcess
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("__metadata:\n  version: 8\n  cacheKey: 10c0\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte("{}"), 0600)).To(Succeed())
+
+			homeDir, err = os.MkdirTemp("", "home")
+			Expect(err).NotTo(HaveOccurred())
+
+			t.Setenv("NODE_ENV", "production")
+
+			executable = &fakes.Executable{}
+
+			installProcess = yarninstall.NewBerryInstallProcess(executable, &fakes.Summer{}, homeDir, scribe.NewEmitter(bytes.NewBuffer(nil)))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(homeDir)).To(Succeed())
+		})
+
+		context("when the project uses the node-modules linker", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: node-modules\n"), 0600)).To(Succeed())
+			})
+
+			it("returns the inputs that ShouldRun hashes", func() {
+				components, err := installProcess.CacheKey(workingDir, "4.1.0")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(components).To(HaveKeyWithValue("yarn-version", "4.1.0"))
+				Expect(components).To(HaveKeyWithValue("NODE_ENV", "production"))
+				Expect(components).To(HaveKey("yarn.lock"))
+				Expect(components).To(HaveKey("package.json"))
+				Expect(components).To(HaveKey(".yarnrc.yml"))
+
+				Expect(executable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the project uses Plug'n'Play", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\n"), 0600)).To(Succeed())
+			})
+
+			it("leaves out NODE_ENV, which the Plug'n'Play install does not depend on", func() {
+				components, err := installProcess.CacheKey(workingDir, "4.1.0")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(components).To(HaveKeyWithValue("yarn-version", "4.1.0"))
+				Expect(components).To(HaveKey("yarn.lock"))
+				Expect(components).To(HaveKey(".yarnrc.yml"))
+				Expect(components).To(HaveKey("package.json"))
+				Expect(components).NotTo(HaveKey("NODE_ENV"))
+			})
+		})
+	})
+
+	context("SetupModules", func() {
+		var (
+			workingDir     string
+			currentLayer   string
+			nextLayer      string
+			installProcess yarninstall.BerryInstallProcess
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			currentLayer, err = os.MkdirTemp("", "current-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			nextLayer, err = os.MkdirTemp("", "next-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: pnpm\n"), 0600)).To(Succeed())
+
+			installProcess = yarninstall.NewBerryInstallProcess(&fakes.Executable{}, &fakes.Summer{}, "some-home-dir", scribe.NewEmitter(bytes.NewBuffer(nil)))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(currentLayer)).To(Succeed())
+			Expect(os.RemoveAll(nextLayer)).To(Succeed())
+		})
+
+		context("when the pnpm linker store is copied from a previous layer", func() {
+			it.Before(func() {
+				store := filepath.Join(currentLayer, "node_modules", ".store")
+				Expect(os.MkdirAll(filepath.Join(store, "leftpad-npm-0.0.1-abc", "package"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(store, "debug-npm-2.6.9-def", "node_modules"), os.ModePerm)).To(Succeed())
+				Expect(os.Symlink(".store/leftpad-npm-0.0.1-abc/package", filepath.Join(currentLayer, "node_modules", "leftpad"))).To(Succeed())
+				Expect(os.Symlink("../../leftpad-npm-0.0.1-abc/package", filepath.Join(store, "debug-npm-2.6.9-def", "node_modules", "leftpad"))).To(Succeed())
+				Expect(os.Symlink(filepath.Join(store, "leftpad-npm-0.0.1-abc", "package"), filepath.Join(currentLayer, "node_modules", "absolute-leftpad"))).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(currentLayer, "workspaces", "packages", "a", "node_modules"), os.ModePerm)).To(Succeed())
+				Expect(os.Symlink(filepath.Join(store, "leftpad-npm-0.0.1-abc", "package"), filepath.Join(currentLayer, "workspaces", "packages", "a", "node_modules", "leftpad"))).To(Succeed())
+			})
+
+			it("keeps the relative store links and points absolute ones at the new layer", func() {
+				path, err := installProcess.SetupModules(workingDir, currentLayer, nextLayer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(path).To(Equal(nextLayer))
+
+				link, err := os.Readlink(filepath.Join(nextLayer, "node_modules", "leftpad"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal(".store/leftpad-npm-0.0.1-abc/package"))
+
+				link, err = os.Readlink(filepath.Join(nextLayer, "node_modules", ".store", "debug-npm-2.6.9-def", "node_modules", "leftpad"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal("../../leftpad-npm-0.0.1-abc/package"))
+
+				link, err = os.Readlink(filepath.Join(nextLayer, "node_modules", "absolute-leftpad"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal(filepath.Join(nextLayer, "node_modules", ".store", "leftpad-npm-0.0.1-abc", "package")))
+
+				link, err = os.Readlink(filepath.Join(nextLayer, "workspaces", "packages", "a", "node_modules", "leftpad"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal(filepath.Join(nextLayer, "node_modules", ".store", "leftpad-npm-0.0.1-abc", "package")))
+
+				Expect(filepath.Join(nextLayer, "node_modules", "leftpad")).To(BeADirectory())
+			})
+		})
+	})
+
+	context("Execute", func() {
+		var (
+			workingDir     string
+			layerPath      string
+			executable     *fakes.Executable
+			executions     []pexec.Execution
+			pluginRuntime  string
+			installProcess yarninstall.BerryInstallProcess
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			layerPath, err = os.MkdirTemp("", "layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: node-modules\n"), 0600)).To(Succeed())
+
+			pluginRuntime = `{"name":"@yarnpkg/plugin-essentials","builtin":true}
+{"name":"@yarnpkg/plugin-workspace-tools","builtin":true}
+`
+
+			executions = nil
+			executable = &fakes.Executable{}
+			executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+				executions = append(executions, execution)
+				if strings.Join(execution.Args, " ") == "plugin runtime --json" {
+					fmt.Fprint(execution.Stdout, pluginRuntime)
+				}
+				return nil
+			}
+
+			installProcess = yarninstall.NewBerryInstallProcess(executable, &fakes.Summer{}, "some-home-dir", scribe.NewEmitter(bytes.NewBuffer(nil)))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(layerPath)).To(Succeed())
+		})
+
+		context("when installing the build layer", func() {
+			it("runs an immutable install without Classic-only flags", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, "", false)).To(Succeed())
+
+				Expect(executions).To(HaveLen(1))
+				Expect(executions[0].Args).To(Equal([]string{"install", "--immutable"}))
+				Expect(executions[0].Dir).To(Equal(workingDir))
+			})
+
+			context("when immutable installs are disabled", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: node-modules\nenableImmutableInstalls: false\n"), 0600)).To(Succeed())
+				})
+
+				it("runs a plain install", func() {
+					Expect(installProcess.Execute(workingDir, layerPath, "", false)).To(Succeed())
+
+					Expect(executions).To(HaveLen(1))
+					Expect(executions[0].Args).To(Equal([]string{"install"}))
+				})
+			})
+		})
+
+		context("when yarn creates node_modules in the project", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"workspaces": ["packages/*"]}`), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "a"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "packages", "a", "package.json"), []byte(`{"name": "a"}`), 0600)).To(Succeed())
+
+				// A previous layer populated by SetupModules and linked into the project
+				Expect(os.MkdirAll(filepath.Join(layerPath, "node_modules", "stale"), os.ModePerm)).To(Succeed())
+				Expect(os.Symlink("/some/tmp/node_modules", filepath.Join(workingDir, "node_modules"))).To(Succeed())
+
+				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					executions = append(executions, execution)
+
+					// The layer contents are restored into the project before the install
+					Expect(filepath.Join(workingDir, "node_modules", "stale")).To(BeADirectory())
+
+					Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "leftpad", "bin"), os.ModePerm)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", ".bin"), os.ModePerm)).To(Succeed())
+					Expect(os.Symlink("../leftpad/bin", filepath.Join(workingDir, "node_modules", ".bin", "leftpad"))).To(Succeed())
+					Expect(os.Symlink("../packages/a", filepath.Join(workingDir, "node_modules", "a"))).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "a", "node_modules", "debug"), os.ModePerm)).To(Succeed())
+
+					return nil
+				}
+			})
+
+			it("moves every node_modules directory into the layer", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, "", false)).To(Succeed())
+
+				Expect(filepath.Join(layerPath, "node_modules", "leftpad")).To(BeADirectory())
+				Expect(filepath.Join(layerPath, "workspaces", "packages", "a", "node_modules", "debug")).To(BeADirectory())
+
+				link, err := os.Readlink(filepath.Join(workingDir, "node_modules"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal("/some/tmp/node_modules"))
+
+				link, err = os.Readlink(filepath.Join(workingDir, "packages", "a", "node_modules"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal(filepath.Join(layerPath, "workspaces", "packages", "a", "node_modules")))
+			})
+
+			it("keeps links within node_modules relative and makes escaping links absolute", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, "", false)).To(Succeed())
+
+				link, err := os.Readlink(filepath.Join(layerPath, "node_modules", ".bin", "leftpad"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal("../leftpad/bin"))
+
+				link, err = os.Readlink(filepath.Join(layerPath, "node_modules", "a"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal(filepath.Join(workingDir, "packages", "a")))
+			})
+		})
+
+		context("when the project uses Plug'n'Play", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\n"), 0600)).To(Succeed())
+
+				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					executions = append(executions, execution)
+					Expect(os.WriteFile(filepath.Join(workingDir, ".pnp.cjs"), []byte("some-pnp-runtime"), 0600)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, ".yarn", "unplugged", "esbuild-npm-0.19.0"), os.ModePerm)).To(Succeed())
+					return nil
+				}
+			})
+
+			it("saves the PnP artifacts in the layer", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, "", false)).To(Succeed())
+
+				content, err := os.ReadFile(filepath.Join(layerPath, "pnp", ".pnp.cjs"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-pnp-runtime"))
+
+				Expect(filepath.Join(layerPath, "pnp", ".yarn", "unplugged", "esbuild-npm-0.19.0")).To(BeADirectory())
+				Expect(filepath.Join(layerPath, "pnp", ".pnp.loader.mjs")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("when a cache layer is provided", func() {
+			var cacheLayerPath string
+
+			it.Before(func() {
+				var err error
+				cacheLayerPath, err = os.MkdirTemp("", "cache-layer")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(`__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"leftpad@npm:~0.0.1":
+  version: 0.0.1
+  resolution: "leftpad@npm:0.0.1"
+  checksum: 10c0/8f0b7f9d67aa2b1c3e4f5a6b7c8d9e0f
+  languageName: node
+  linkType: hard
+
+"@types/node@npm:^20.1.0":
+  version: 20.1.0
+  resolution: "@types/node@npm:20.1.0"
+  checksum: 10c0/0123456789abcdef
+  languageName: node
+  linkType: hard
+`), 0600)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(cacheLayerPath, "cache"), os.ModePerm)).To(Succeed())
+				for _, name := range []string{
+					"leftpad-npm-0.0.1-787440a011-10c0.zip",
+					"leftpad-npm-0.0.1-787440a011-8f0b7f9d67.zip",
+					"leftpad-npm-0.0.1-787440a011-0000000000.zip",
+					"@types-node-npm-20.1.0-c5561d67cd-10c0.zip",
+					"debug-npm-2.6.9-7d4cb597dc-0123456789.zip",
+					"ms-npm-2.0.0-9e1101a471-10c0.zip",
+					"ms-npm-2.0.0-9e1101a471-8.zip",
+				} {
+					Expect(os.WriteFile(filepath.Join(cacheLayerPath, "cache", name), []byte{}, 0600)).To(Succeed())
+				}
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(cacheLayerPath)).To(Succeed())
+			})
+
+			it("keeps the global cache in the cache layer", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, cacheLayerPath, false)).To(Succeed())
+
+				Expect(executions).To(HaveLen(1))
+				Expect(executions[0].Env).To(ContainElements(
+					fmt.Sprintf("YARN_GLOBAL_FOLDER=%s", cacheLayerPath),
+					"YARN_ENABLE_GLOBAL_CACHE=true",
+				))
+			})
+
+			it("removes the archives that are no longer in the lockfile", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, cacheLayerPath, false)).To(Succeed())
+
+				files, err := filepath.Glob(filepath.Join(cacheLayerPath, "cache", "*.zip"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(ConsistOf(
+					filepath.Join(cacheLayerPath, "cache", "@types-node-npm-20.1.0-c5561d67cd-10c0.zip"),
+					filepath.Join(cacheLayerPath, "cache", "leftpad-npm-0.0.1-787440a011-10c0.zip"),
+					filepath.Join(cacheLayerPath, "cache", "leftpad-npm-0.0.1-787440a011-8f0b7f9d67.zip"),
+				))
+			})
+
+			context("when the project uses PnP", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\n"), 0600)).To(Succeed())
+				})
+
+				it("uses the cache layer as a mirror of the cache in the modules layer", func() {
+					Expect(installProcess.Execute(workingDir, layerPath, cacheLayerPath, false)).To(Succeed())
+
+					Expect(executions).To(HaveLen(1))
+					Expect(executions[0].Env).To(ContainElements(
+						fmt.Sprintf("YARN_CACHE_FOLDER=%s", filepath.Join(layerPath, "cache")),
+						fmt.Sprintf("YARN_GLOBAL_FOLDER=%s", cacheLayerPath),
+						"YARN_ENABLE_GLOBAL_CACHE=false",
+						"YARN_ENABLE_MIRROR=true",
+					))
+				})
+			})
+		})
+
+		context("when installing the launch layer", func() {
+			it("focuses all workspaces on their production dependencies", func() {
+				Expect(installProcess.Execute(workingDir, layerPath, "", true)).To(Succeed())
+
+				Expect(executions).To(HaveLen(2))
+				Expect(executions[0].Args).To(Equal([]string{"plugin", "runtime", "--json"}))
+				Expect(executions[1].Args).To(Equal([]string{"workspaces", "focus", "--all", "--production"}))
+				Expect(executions[1].Dir).To(Equal(workingDir))
+			})
+
+			context("when the project uses PnP", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\n"), 0600)).To(Succeed())
+				})
+
+				it("focuses all workspaces with the cache in the layer", func() {
+					Expect(installProcess.Execute(workingDir, layerPath, "", true)).To(Succeed())
+
+					Expect(executions).To(HaveLen(2))
+					Expect(executions[1].Args).To(Equal([]string{"workspaces", "focus", "--all", "--production"}))
+					Expect(executions[1].Env).To(ContainElement(fmt.Sprintf("YARN_CACHE_FOLDER=%s", filepath.Join(layerPath, "cache"))))
+				})
+			})
+
+			context("when the workspace-tools plugin is not available", func() {
+				it.Before(func() {
+					pluginRuntime = `{"name":"@yarnpkg/plugin-essentials","builtin":true}
+`
+				})
+
+				it("returns an error rather than shipping devDependencies", func() {
+					err := installProcess.Execute(workingDir, layerPath, "", true)
+					Expect(err).To(MatchError(ContainSubstring("cannot remove devDependencies from the launch layer")))
+					Expect(err).To(MatchError(ContainSubstring("yarn plugin import workspace-tools")))
+					Expect(executions).To(HaveLen(1))
+				})
+			})
+
+			context("when the plugins cannot be listed", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						fmt.Fprint(execution.Stdout, "some-output")
+						return errors.New("some-error")
+					}
+				})
+
+				it("returns an error", func() {
+					err := installProcess.Execute(workingDir, layerPath, "", true)
+					Expect(err).To(MatchError(ContainSubstring("failed to execute yarn plugin runtime: some-error")))
+					Expect(err).To(MatchError(ContainSubstring("some-output")))
+				})
+			})
+		})
+
+		context("when the install fails", func() {
+			it.Before(func() {
+				executable.ExecuteCall.Returns.Error = errors.New("some-error")
+				executable.ExecuteCall.Stub = nil
+			})
+
+			it("returns an error", func() {
+				err := installProcess.Execute(workingDir, layerPath, "", false)
+				Expect(err).To(MatchError("failed to execute yarn install: some-error"))
+			})
+		})
+	})
+
+	context("RestorePnPArtifacts", func() {
+		var (
+			workingDir string
+			layerPath  string
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			layerPath, err = os.MkdirTemp("", "layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(layerPath, "pnp", ".yarn", "unplugged", "esbuild-npm-0.19.0"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layerPath, "pnp", ".pnp.cjs"), []byte("cached-pnp-runtime"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".pnp.cjs"), []byte("previous-pnp-runtime"), 0600)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(layerPath)).To(Succeed())
+		})
+
+		it("copies the saved artifacts into the project", func() {
+			Expect(yarninstall.RestorePnPArtifacts(workingDir, layerPath)).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(workingDir, ".pnp.cjs"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("cached-pnp-runtime"))
+
+			Expect(filepath.Join(workingDir, ".yarn", "unplugged", "esbuild-npm-0.19.0")).To(BeADirectory())
+			Expect(filepath.Join(workingDir, ".pnp.loader.mjs")).NotTo(BeAnExistingFile())
+		})
+	})
+}
@@ -0,0 +1,202 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeBindings merges the given entry of several bindings into one file.
+// Bindings that set the same setting to different values are rejected.
+func mergeBindings(typ, entry string, bindings []servicebindings.Binding) (string, func() error, error) {
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	var contents [][]byte
+	for _, binding := range bindings {
+		content, err := binding.Entries[entry].ReadBytes()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read binding '%s': %w", binding.Name, err)
+		}
+		contents = append(contents, content)
+	}
+
+	conflict := func(key string, first, second int) error {
+		return fmt.Errorf("failed: bindings '%s' and '%s' of type '%s' both configure '%s'", bindings[first].Name, bindings[second].Name, typ, key)
+	}
+
+	var merged []byte
+	switch entry {
+	case ".npmrc", ".yarnrc":
+		parse := parseNpmrcLine
+		if entry == ".yarnrc" {
+			parse = parseYarnrcLine
+		}
+
+		var lines []string
+		values := map[string]string{}
+		sources := map[string]int{}
+		for i, content := range contents {
+			for _, line := range strings.Split(string(content), "\n") {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+
+				key, value, ok := parse(line)
+				if !ok {
+					lines = append(lines, strings.TrimSpace(line))
+					continue
+				}
+
+				if source, ok := sources[key]; ok && source != i && !strings.HasSuffix(key, "[]") {
+					if values[key] != value {
+						return "", nil, conflict(key, source, i)
+					}
+					continue
+				}
+
+				values[key] = value
+				sources[key] = i
+				lines = append(lines, strings.TrimSpace(line))
+			}
+		}
+
+		merged = []byte(strings.Join(lines, "\n") + "\n")
+
+	case YarnrcYml:
+		config := map[string]interface{}{}
+		sources := map[string]int{}
+		for i, content := range contents {
+			binding := map[string]interface{}{}
+			err := yaml.Unmarshal(content, &binding)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to parse binding '%s': %w", bindings[i].Name, err)
+			}
+
+			key, source, ok := mergeYarnrcBinding(config, binding, sources, i, "")
+			if !ok {
+				return "", nil, conflict(key, source, i)
+			}
+		}
+
+		var err error
+		merged, err = yaml.Marshal(config)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode merged bindings: %w", err)
+		}
+
+	default:
+		return "", nil, fmt.Errorf("failed: cannot merge more than one binding of type '%s'", typ)
+	}
+
+	dir, err := os.MkdirTemp("", fmt.Sprintf("%s-bindings", typ))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create directory for merged bindings: %w", err)
+	}
+
+	cleanup := func() error {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return fmt.Errorf("failed to remove merged bindings: %w", err)
+		}
+		return nil
+	}
+
+	path := filepath.Join(dir, entry)
+	err = os.WriteFile(path, merged, 0600)
+	if err != nil {
+		return "", nil, errors.Join(fmt.Errorf("failed to write merged bindings: %w", err), os.RemoveAll(dir))
+	}
+
+	return path, cleanup, nil
+}
+
+// mergeYarnrcBinding merges a .yarnrc.yml binding into config.
+func mergeYarnrcBinding(config, binding map[string]interface{}, sources map[string]int, index int, prefix string) (string, int, bool) {
+	keys := make([]string, 0, len(binding))
+	for key := range binding {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := prefix + key
+		value := binding[key]
+
+		existing, isMap := config[key].(map[string]interface{})
+		override, overridesMap := value.(map[string]interface{})
+		if isMap && overridesMap {
+			conflictKey, source, ok := mergeYarnrcBinding(existing, override, sources, index, path+".")
+			if !ok {
+				return conflictKey, source, false
+			}
+			continue
+		}
+
+		if current, ok := config[key]; ok && !reflect.DeepEqual(current, value) {
+			return path, sources[path], false
+		}
+
+		config[key] = value
+		if overridesMap {
+			markYarnrcSources(override, sources, index, path+".")
+		}
+		sources[path] = index
+	}
+
+	return "", 0, true
+}
+
+func markYarnrcSources(config map[string]interface{}, sources map[string]int, index int, prefix string) {
+	for key, value := range config {
+		sources[prefix+key] = index
+		if nested, ok := value.(map[string]interface{}); ok {
+			markYarnrcSources(nested, sources, index, prefix+key+".")
+		}
+	}
+}
+
+// parseNpmrcLine parses a key=value line of an .npmrc.
+func parseNpmrcLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"`), true
+}
+
+// parseYarnrcLine parses a `key value` line of a Yarn Classic .yarnrc, where
+// both the key and the value may be quoted.
+func parseYarnrcLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	var key, value string
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return "", "", false
+		}
+		key, value = line[1:end+1], line[end+2:]
+	} else {
+		key, value, _ = strings.Cut(line, " ")
+	}
+
+	return key, strings.Trim(strings.TrimSpace(value), `"`), true
+}
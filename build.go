@@ -1,10 +1,14 @@
 package yarninstall
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/paketo-buildpacks/libnodejs"
@@ -12,8 +16,8 @@ import (
 	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
-	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
 )
 
 //go:generate faux --interface SymlinkManager --output fakes/symlink_manager.go
@@ -24,9 +28,10 @@ type SymlinkManager interface {
 
 //go:generate faux --interface InstallProcess --output fakes/install_process.go
 type InstallProcess interface {
-	ShouldRun(workingDir string, metadata map[string]interface{}) (run bool, sha string, err error)
+	CacheKey(workingDir, yarnVersion string) (components map[string]string, err error)
+	ShouldRun(workingDir, yarnVersion string, metadata map[string]interface{}) (run bool, sha string, err error)
 	SetupModules(workingDir, currentModulesLayerPath, nextModulesLayerPath string) (string, error)
-	Execute(workingDir, modulesLayerPath string, launch bool) error
+	Execute(workingDir, modulesLayerPath, cacheLayerPath string, launch bool) error
 }
 
 //go:generate faux --interface EntryResolver --output fakes/entry_resolver.go
@@ -36,12 +41,12 @@ type EntryResolver interface {
 
 //go:generate faux --interface SBOMGenerator --output fakes/sbom_generator.go
 type SBOMGenerator interface {
-	Generate(dir string) (sbom.SBOM, error)
+	Generate(projectPath, modulesLayerPath string) (LayerSBOM, error)
 }
 
 //go:generate faux --interface ConfigurationManager --output fakes/configuration_manager.go
 type ConfigurationManager interface {
-	DeterminePath(typ, platformDir, entry string) (path string, err error)
+	DeterminePath(typ, platformDir, entry string) (path string, cleanup func() error, err error)
 }
 
 func Build(entryResolver EntryResolver,
@@ -53,7 +58,7 @@ func Build(entryResolver EntryResolver,
 	clock chronos.Clock,
 	logger scribe.Emitter,
 	tmpDir string) packit.BuildFunc {
-	return func(context packit.BuildContext) (packit.BuildResult, error) {
+	return func(context packit.BuildContext) (result packit.BuildResult, err error) {
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
 
 		projectPath, err := libnodejs.FindProjectPath(context.WorkingDir)
@@ -62,37 +67,91 @@ func Build(entryResolver EntryResolver,
 		}
 
 		// Determine Yarn version and provision type
-		yarnVersion, err := DetermineYarnVersion(projectPath)
+		yarnVersionResolution, err := ResolveYarnVersion(projectPath)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
+		yarnVersion := yarnVersionResolution.Version
 
 		yarnrcConfig, err := ParseYarnrcYml(projectPath)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		provisionType := DetermineProvisionType(projectPath, yarnrcConfig)
+		provisionType, err := DetermineProvisionType(projectPath, yarnrcConfig)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 		logger.Process("Detected Yarn %s, providing %s", yarnVersion, provisionType)
+		if len(yarnVersionResolution.Conflicts) > 0 {
+			var ignored []string
+			for _, signal := range yarnVersionResolution.Conflicts {
+				ignored = append(ignored, fmt.Sprintf("%s (%s)", signal.Source, signal.Version))
+			}
+			logger.Subprocess("Conflicting Yarn version signals: using %s (%s) over %s",
+				yarnVersionResolution.Source, yarnVersion, strings.Join(ignored, ", "))
+		}
+
+		yarnRelease, err := FindYarnRelease(projectPath, yarnrcConfig)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		manifest, err := ParsePackageManifest(projectPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		var yarnExecutable Executable = pexec.NewExecutable("yarn")
+		if yarnRelease != "" {
+			logger.Subprocess("Using vendored Yarn release %s", yarnRelease)
+			yarnExecutable = NewYarnReleaseExecutable(pexec.NewExecutable("node"), yarnRelease)
+		} else if pinned := manifest.YarnVersion(); yarnGeneration(pinned) == YarnBerry {
+			logger.Subprocess("Using Yarn %s through corepack", pinned)
+			yarnExecutable = NewCorepackExecutable(pexec.NewExecutable("corepack"))
+		}
+
+		exactYarnVersion := determineYarnVersion(yarnExecutable, projectPath, yarnRelease, manifest, logger)
 
 		// Choose the appropriate install process
 		var actualInstallProcess InstallProcess
 		if yarnVersion == YarnBerry {
 			logger.Subprocess("Using Yarn Berry install process")
 			actualInstallProcess = NewBerryInstallProcess(
-				pexec.NewExecutable("yarn"),
+				yarnExecutable,
 				fs.NewChecksumCalculator(),
+				homeDir,
 				logger,
 			)
 		} else {
 			logger.Subprocess("Using Yarn Classic install process")
 			actualInstallProcess = installProcess
+			if yarnRelease != "" {
+				actualInstallProcess = NewYarnInstallProcess(yarnExecutable, fs.NewChecksumCalculator(), homeDir, logger)
+			}
 		}
 
-		globalNpmrcPath, err := configurationManager.DeterminePath("npmrc", context.Platform.Path, ".npmrc")
+		// The configuration files that were written for the build may hold
+		// credentials and are removed once it is done, even when it fails
+		var cleanups []func() error
+		defer func() {
+			for _, cleanup := range cleanups {
+				if cleanup == nil {
+					continue
+				}
+
+				cleanupErr := cleanup()
+				if cleanupErr != nil && err == nil {
+					result, err = packit.BuildResult{}, cleanupErr
+				}
+			}
+		}()
+
+		globalNpmrcPath, cleanup, err := configurationManager.DeterminePath("npmrc", context.Platform.Path, ".npmrc")
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
+		cleanups = append(cleanups, cleanup)
 
 		if globalNpmrcPath != "" {
 			err = symlinker.Link(globalNpmrcPath, filepath.Join(homeDir, ".npmrc"))
@@ -101,10 +160,11 @@ func Build(entryResolver EntryResolver,
 			}
 		}
 
-		globalYarnrcPath, err := configurationManager.DeterminePath("yarnrc", context.Platform.Path, ".yarnrc")
+		globalYarnrcPath, cleanup, err := configurationManager.DeterminePath("yarnrc", context.Platform.Path, ".yarnrc")
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
+		cleanups = append(cleanups, cleanup)
 
 		if globalYarnrcPath != "" {
 			err = symlinker.Link(globalYarnrcPath, filepath.Join(homeDir, ".yarnrc"))
@@ -113,16 +173,152 @@ func Build(entryResolver EntryResolver,
 			}
 		}
 
+		// Yarn Berry ignores .npmrc and .yarnrc, its user-level configuration
+		// is ~/.yarnrc.yml
+		globalYarnrcYmlPath, cleanup, err := configurationManager.DeterminePath("yarnrc-yml", context.Platform.Path, YarnrcYml)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		cleanups = append(cleanups, cleanup)
+
+		if globalYarnrcYmlPath != "" {
+			var restoreYarnrcYml func() error
+			restoreYarnrcYml, err = MergeYarnrcYml(globalYarnrcYmlPath, homeDir)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// The credentials of the binding must not outlive the build, even
+			// when it fails
+			defer func() {
+				restoreErr := restoreYarnrcYml()
+				if restoreErr != nil && err == nil {
+					result, err = packit.BuildResult{}, restoreErr
+				}
+			}()
+		}
+
+		// The components of the cache key are recorded in the layer metadata
+		// so that the next build can explain why it reinstalls
+		cacheKey, err := actualInstallProcess.CacheKey(projectPath, exactYarnVersion)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
 		// Use the detected provision type for layer resolution
 		launch, build := entryResolver.MergeLayerTypes(provisionType, context.Plan.Entries)
 
+		// The Plug'n'Play artifacts of a zero-install project are part of the
+		// source and must not be replaced by the ones saved in a cached layer
+		hasPnpFiles, err := HasPnpFiles(projectPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		restorePnP := provisionType == PlanDependencyYarnPkgs && !hasPnpFiles
+
 		sbomDisabled, err := checkSbomDisabled()
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
+		// An incremental install needs a lockfile to check the reconciled
+		// node_modules against, PnP projects have no node_modules to reuse.
+		// Only the build modules are restored from the cache, the content of
+		// the launch modules is not available to reconcile.
+		incrementalInstall, err := checkIncrementalInstall()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		hasLockfile, err := fs.Exists(filepath.Join(projectPath, "yarn.lock"))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		incrementalInstall = incrementalInstall && hasLockfile && provisionType == PlanDependencyNodeModules
+
+		// The launch modules can be derived from the build modules by
+		// removing the devDependencies, as long as the native addons in the
+		// build modules were compiled for the current node. Packages of the
+		// pnpm linker live in a store that is shared between dependencies, so
+		// they are installed instead.
+		nodeABI, err := NodeABI()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		canPrune := hasLockfile && provisionType == PlanDependencyNodeModules &&
+			(yarnrcConfig == nil || yarnrcConfig.NodeLinker != NodeLinkerPnpm)
+		var pruneSource string
+		var dependencyChangesLogged bool
+
 		var layers []packit.Layer
 		var currentModLayer string
+
+		// The download cache is kept in a cache-only layer so that it survives
+		// the reset of the modules layers when yarn.lock changes. Berry
+		// projects that commit their cache to the repository do not need one.
+		useCacheLayer := true
+		if yarnVersion == YarnBerry {
+			hasCache, err := HasYarnCache(projectPath, yarnrcConfig)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			globalCache := yarnrcConfig != nil && yarnrcConfig.EnableGlobalCache != nil && *yarnrcConfig.EnableGlobalCache
+			useCacheLayer = !hasCache || globalCache
+		}
+
+		var cacheLayer packit.Layer
+		var cacheLayerPath string
+		if useCacheLayer && (build || launch) {
+			cacheLayer, err = context.Layers.Get("yarn-cache")
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			err = os.MkdirAll(cacheLayer.Path, os.ModePerm)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			cacheLayer.Cache = true
+			cacheLayerPath = cacheLayer.Path
+		}
+
+		// The SBOMs of the modules layers are kept in a cache-only layer, as
+		// the content of the launch modules is not restored when it is reused
+		var sbomLayer packit.Layer
+		if !sbomDisabled && (build || launch) {
+			sbomLayer, err = context.Layers.Get("modules-sbom")
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			err = os.MkdirAll(sbomLayer.Path, os.ModePerm)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			sbomLayer.Cache = true
+		}
+
+		// The lockfile snapshots of the modules layers are kept in a
+		// cache-only layer, their metadata only records the digest
+		var snapshotLayer packit.Layer
+		if build || launch {
+			snapshotLayer, err = context.Layers.Get("lockfile-snapshots")
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			err = os.MkdirAll(snapshotLayer.Path, os.ModePerm)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			snapshotLayer.Cache = true
+		}
 		if build {
 			layer, err := context.Layers.Get("build-modules")
 			if err != nil {
@@ -131,7 +327,7 @@ func Build(entryResolver EntryResolver,
 
 			logger.Process("Resolving installation process")
 
-			run, sha, err := actualInstallProcess.ShouldRun(projectPath, layer.Metadata)
+			run, sha, err := actualInstallProcess.ShouldRun(projectPath, exactYarnVersion, layer.Metadata)
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
@@ -139,8 +335,21 @@ func Build(entryResolver EntryResolver,
 			if run {
 				logger.Subprocess("Selected default build process: 'yarn install'")
 				logger.Break()
+				snapshotPath := filepath.Join(snapshotLayer.Path, layer.Name+".json")
+				logInvalidation(logger, layer.Metadata, cacheKey, snapshotPath, projectPath)
 				logger.Process("Executing build environment install process")
 
+				previousLockfile, hasPreviousLockfile := ReadLockfileSnapshot(snapshotPath, layer.Metadata)
+
+				var previousModules string
+				if incrementalInstall {
+					previousModules, err = preserveNodeModules(layer.Path)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					defer os.RemoveAll(previousModules)
+				}
+
 				layer, err = layer.Reset()
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -151,8 +360,16 @@ func Build(entryResolver EntryResolver,
 					return packit.BuildResult{}, err
 				}
 
+				if previousModules != "" {
+					logger.Subprocess("Restoring node_modules from the previous build for an incremental install")
+					err = restoreNodeModulesLayer(previousModules, layer.Path)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
 				duration, err := clock.Measure(func() error {
-					return actualInstallProcess.Execute(projectPath, layer.Path, false)
+					return executeInstall(actualInstallProcess, logger, projectPath, layer.Path, cacheLayerPath, false, previousModules != "")
 				})
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -163,14 +380,36 @@ func Build(entryResolver EntryResolver,
 
 				layer.Metadata = map[string]interface{}{
 					"cache_sha": sha,
+					"cache_key": cacheKey,
+				}
+
+				changes, reported, err := recordDependencyChanges(logger, projectPath, snapshotPath, layer.Path, layer.Metadata, previousLockfile, hasPreviousLockfile)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if reported {
+					logDependencyChanges(logger, changes)
+					dependencyChangesLogged = true
 				}
 
-				// Only setup node_modules symlink for non-PnP projects
+				if nodeABI != "" {
+					layer.Metadata["node_abi"] = nodeABI
+				}
+				pruneSource = layer.Path
+
+				// Only setup node_modules symlink for non-PnP projects, PnP
+				// projects get their package cache linked instead
 				if provisionType == PlanDependencyNodeModules {
 					err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
+				} else {
+					err = ensurePnPCacheSymlink(projectPath, yarnrcConfig, layer.Path, tmpDir)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
 				}
 
 				path := filepath.Join(layer.Path, "node_modules", ".bin")
@@ -184,20 +423,7 @@ func Build(entryResolver EntryResolver,
 					logger.Break()
 
 				} else {
-					logger.GeneratingSBOM(layer.Path)
-					var sbomContent sbom.SBOM
-					duration, err = clock.Measure(func() error {
-						sbomContent, err = sbomGenerator.Generate(context.WorkingDir)
-						return err
-					})
-					if err != nil {
-						return packit.BuildResult{}, err
-					}
-					logger.Action("Completed in %s", duration.Round(time.Millisecond))
-					logger.Break()
-
-					logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-					layer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+					layer.SBOM, err = generateLayerSBOM(sbomGenerator, clock, logger, projectPath, layer.Path, filepath.Join(sbomLayer.Path, layer.Name), sha, context.BuildpackInfo.SBOMFormats)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
@@ -205,12 +431,39 @@ func Build(entryResolver EntryResolver,
 			} else {
 				logger.Process("Reusing cached layer %s", layer.Path)
 
-				// Only setup node_modules symlink for non-PnP projects
+				if previousABI, ok := layer.Metadata["node_abi"].(string); ok && nodeABI != "" && previousABI == nodeABI {
+					pruneSource = layer.Path
+				}
+
+				// Only setup node_modules symlink for non-PnP projects, PnP
+				// projects get their package cache linked instead
 				if provisionType == PlanDependencyNodeModules {
 					err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
+				} else {
+					err = ensurePnPCacheSymlink(projectPath, yarnrcConfig, layer.Path, tmpDir)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
+				if restorePnP {
+					err = RestorePnPArtifacts(projectPath, layer.Path)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
+				if !sbomDisabled {
+					// ShouldRun returns no checksum for a reused layer, the SBOM
+					// is keyed on the one recorded when the layer was installed
+					key, _ := layer.Metadata["cache_sha"].(string)
+					layer.SBOM, err = cachedLayerSBOM(sbomGenerator, clock, logger, projectPath, layer.Path, filepath.Join(sbomLayer.Path, layer.Name), key, context.BuildpackInfo.SBOMFormats)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
 				}
 			}
 
@@ -228,7 +481,7 @@ func Build(entryResolver EntryResolver,
 
 			logger.Process("Resolving installation process")
 
-			run, sha, err := actualInstallProcess.ShouldRun(projectPath, layer.Metadata)
+			run, sha, err := actualInstallProcess.ShouldRun(projectPath, exactYarnVersion, layer.Metadata)
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
@@ -236,20 +489,69 @@ func Build(entryResolver EntryResolver,
 			if run {
 				logger.Subprocess("Selected default build process: 'yarn install'")
 				logger.Break()
+				snapshotPath := filepath.Join(snapshotLayer.Path, layer.Name+".json")
+				logInvalidation(logger, layer.Metadata, cacheKey, snapshotPath, projectPath)
 				logger.Process("Executing launch environment install process")
 
+				prune := canPrune && pruneSource != ""
+
+				previousLockfile, hasPreviousLockfile := ReadLockfileSnapshot(snapshotPath, layer.Metadata)
+
 				layer, err = layer.Reset()
 				if err != nil {
 					return packit.BuildResult{}, err
 				}
 
-				_, err = actualInstallProcess.SetupModules(projectPath, currentModLayer, layer.Path)
-				if err != nil {
-					return packit.BuildResult{}, err
+				if prune {
+					// Nothing modifies the files of the pruned tree in place, so
+					// they can be shared with the build modules
+					var strategy string
+					duration, err := clock.Measure(func() error {
+						strategy, err = copyModulesLayer(pruneSource, layer.Path, true)
+						return err
+					})
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					logger.Subprocess("Copied node_modules from %s (%s) in %s", pruneSource, strategy, duration.Round(time.Millisecond))
+				} else {
+					_, err = actualInstallProcess.SetupModules(projectPath, currentModLayer, layer.Path)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
+				if prune {
+					logger.Subprocess("Pruning devDependencies from the build modules")
 				}
 
 				duration, err := clock.Measure(func() error {
-					return actualInstallProcess.Execute(projectPath, layer.Path, true)
+					if prune {
+						removed, err := PruneDevDependencies(projectPath, layer.Path)
+						if err == nil {
+							logger.Action("Removed %d package(s)", removed)
+							return nil
+						}
+
+						// Pruning only saves an install, so a tree that cannot be
+						// pruned is replaced by a production install
+						logger.Subprocess("Pruning devDependencies failed (%s), falling back to a production install", err)
+
+						for _, name := range []string{"node_modules", "workspaces"} {
+							err = os.RemoveAll(filepath.Join(layer.Path, name))
+							if err != nil {
+								return fmt.Errorf("failed to remove pruned %s directory: %w", name, err)
+							}
+						}
+
+						_, err = actualInstallProcess.SetupModules(projectPath, currentModLayer, layer.Path)
+						if err != nil {
+							return err
+						}
+					}
+
+					return executeInstall(actualInstallProcess, logger, projectPath, layer.Path, cacheLayerPath, true, false)
 				})
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -259,23 +561,54 @@ func Build(entryResolver EntryResolver,
 				logger.Break()
 
 				if !build {
-					// Only setup node_modules symlink for non-PnP projects
+					// Only setup node_modules symlink for non-PnP projects, PnP
+					// projects get their package cache linked instead
 					if provisionType == PlanDependencyNodeModules {
 						err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 						if err != nil {
 							return packit.BuildResult{}, err
 						}
+					} else {
+						err = ensurePnPCacheSymlink(projectPath, yarnrcConfig, layer.Path, tmpDir)
+						if err != nil {
+							return packit.BuildResult{}, err
+						}
 					}
 				}
 
 				layer.Metadata = map[string]interface{}{
 					"cache_sha": sha,
+					"cache_key": cacheKey,
+				}
+
+				changes, reported, err := recordDependencyChanges(logger, projectPath, snapshotPath, layer.Path, layer.Metadata, previousLockfile, hasPreviousLockfile)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				// The launch modules usually changed in the same way as the
+				// build modules, whose changes are already listed
+				if reported && !dependencyChangesLogged {
+					logDependencyChanges(logger, changes)
 				}
 
 				path := filepath.Join(layer.Path, "node_modules", ".bin")
 				layer.LaunchEnv.Append("PATH", path, string(os.PathListSeparator))
 				layer.LaunchEnv.Default("NODE_PROJECT_PATH", projectPath)
 
+				// Without node_modules, node can only resolve packages through
+				// the PnP runtime
+				if provisionType == PlanDependencyYarnPkgs {
+					nodeOptions, err := PnPNodeOptions(projectPath)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					if nodeOptions != "" {
+						layer.LaunchEnv.Append("NODE_OPTIONS", nodeOptions, " ")
+					}
+				}
+
 				logger.EnvironmentVariables(layer)
 
 				if sbomDisabled {
@@ -283,20 +616,7 @@ func Build(entryResolver EntryResolver,
 					logger.Break()
 
 				} else {
-					logger.GeneratingSBOM(layer.Path)
-					var sbomContent sbom.SBOM
-					duration, err = clock.Measure(func() error {
-						sbomContent, err = sbomGenerator.Generate(context.WorkingDir)
-						return err
-					})
-					if err != nil {
-						return packit.BuildResult{}, err
-					}
-					logger.Action("Completed in %s", duration.Round(time.Millisecond))
-					logger.Break()
-
-					logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-					layer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+					layer.SBOM, err = generateLayerSBOM(sbomGenerator, clock, logger, projectPath, layer.Path, filepath.Join(sbomLayer.Path, layer.Name), sha, context.BuildpackInfo.SBOMFormats)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
@@ -307,22 +627,62 @@ func Build(entryResolver EntryResolver,
 			} else {
 				logger.Process("Reusing cached layer %s", layer.Path)
 				if !build {
-					// Only setup node_modules symlink for non-PnP projects
+					// Only setup node_modules symlink for non-PnP projects, PnP
+					// projects get their package cache linked instead
 					if provisionType == PlanDependencyNodeModules {
 						err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 						if err != nil {
 							return packit.BuildResult{}, err
 						}
+					} else {
+						err = ensurePnPCacheSymlink(projectPath, yarnrcConfig, layer.Path, tmpDir)
+						if err != nil {
+							return packit.BuildResult{}, err
+						}
+					}
+				}
+
+				// The launch artifacts replace any restored from the build layer
+				if restorePnP {
+					err = RestorePnPArtifacts(projectPath, layer.Path)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
+				if !sbomDisabled {
+					// ShouldRun returns no checksum for a reused layer, the SBOM
+					// is keyed on the one recorded when the layer was installed
+					key, _ := layer.Metadata["cache_sha"].(string)
+					layer.SBOM, err = cachedLayerSBOM(sbomGenerator, clock, logger, projectPath, layer.Path, filepath.Join(sbomLayer.Path, layer.Name), key, context.BuildpackInfo.SBOMFormats)
+					if err != nil {
+						return packit.BuildResult{}, err
 					}
 				}
 			}
 
 			layer.Launch = true
 
+			// The Plug'n'Play artifacts are copied into the app from the
+			// layer whenever it is reused, so its content must be restored
+			layer.Cache = provisionType == PlanDependencyYarnPkgs
+
 			layers = append(layers, layer)
 
 		}
 
+		if cacheLayerPath != "" {
+			layers = append(layers, cacheLayer)
+		}
+
+		if sbomLayer.Path != "" {
+			layers = append(layers, sbomLayer)
+		}
+
+		if snapshotLayer.Path != "" {
+			layers = append(layers, snapshotLayer)
+		}
+
 		err = symlinker.Unlink(filepath.Join(homeDir, ".npmrc"))
 		if err != nil {
 			return packit.BuildResult{}, err
@@ -350,6 +710,205 @@ func checkSbomDisabled() (bool, error) {
 	return false, nil
 }
 
+func checkIncrementalInstall() (bool, error) {
+	if incrementalStr, ok := os.LookupEnv("BP_YARN_INCREMENTAL_INSTALL"); ok {
+		incremental, err := strconv.ParseBool(incrementalStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_YARN_INCREMENTAL_INSTALL value %s: %w", incrementalStr, err)
+		}
+		return incremental, nil
+	}
+	return false, nil
+}
+
+// preserveNodeModules moves the node_modules of a layer out of the way.
+func preserveNodeModules(layerPath string) (string, error) {
+	exists, err := fs.Exists(filepath.Join(layerPath, "node_modules"))
+	if err != nil {
+		return "", fmt.Errorf("failed to stat node_modules directory: %w", err)
+	}
+
+	if !exists {
+		return "", nil
+	}
+
+	previousPath, err := os.MkdirTemp(filepath.Dir(layerPath), fmt.Sprintf(".%s-previous-", filepath.Base(layerPath)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory for previous node_modules: %w", err)
+	}
+
+	for _, name := range []string{"node_modules", "workspaces"} {
+		err = os.Rename(filepath.Join(layerPath, name), filepath.Join(previousPath, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to preserve %s directory: %w", name, err)
+		}
+	}
+
+	return previousPath, nil
+}
+
+// restoreNodeModulesLayer moves the trees saved by preserveNodeModules back
+// into the modules layer, replacing whatever the install process set up.
+func restoreNodeModulesLayer(previousPath, layerPath string) error {
+	for _, name := range []string{"node_modules", "workspaces"} {
+		exists, err := fs.Exists(filepath.Join(previousPath, name))
+		if err != nil {
+			return fmt.Errorf("failed to stat previous %s directory: %w", name, err)
+		}
+
+		if !exists {
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Join(layerPath, name))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s directory: %w", name, err)
+		}
+
+		err = os.Rename(filepath.Join(previousPath, name), filepath.Join(layerPath, name))
+		if err != nil {
+			return fmt.Errorf("failed to restore previous %s directory: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// determineYarnVersion returns the version of the yarn that installs the project.
+func determineYarnVersion(executable Executable, projectPath, yarnRelease string, manifest PackageManifest, logger scribe.Emitter) string {
+	if version := yarnReleaseVersion(yarnRelease); version != "" {
+		return version
+	}
+
+	if version := manifest.YarnVersion(); version != "" {
+		return version
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err := executable.Execute(pexec.Execution{
+		Args:   []string{"--version"},
+		Stdout: buffer,
+		Stderr: io.Discard,
+		Dir:    projectPath,
+	})
+	if err != nil {
+		logger.Subprocess("Failed to determine the yarn version (%s), leaving it out of the cache key", err)
+		return ""
+	}
+
+	return strings.TrimSpace(buffer.String())
+}
+
+// recordDependencyChanges snapshots yarn.lock and reports changed dependencies.
+func recordDependencyChanges(logger scribe.Emitter, projectPath, snapshotPath, layerPath string, metadata map[string]interface{}, previous lockfile.Lockfile, hasPrevious bool) (lockfile.Changes, bool, error) {
+	current, err := lockfile.Parse(filepath.Join(projectPath, YarnLock))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Subprocess("Failed to parse yarn.lock (%s), skipping the dependency changes report", err)
+		}
+		return lockfile.Changes{}, false, nil
+	}
+
+	err = SaveLockfileSnapshot(current, snapshotPath, metadata)
+	if err != nil {
+		return lockfile.Changes{}, false, err
+	}
+
+	if !hasPrevious {
+		return lockfile.Changes{}, false, nil
+	}
+
+	changes, err := ReportDependencyChanges(previous, current, layerPath)
+	if err != nil {
+		return lockfile.Changes{}, false, err
+	}
+
+	return changes, true, nil
+}
+
+// executeInstall runs the install process, falling back to a clean install
+// when an incremental one does not match yarn.lock.
+func executeInstall(installProcess InstallProcess, logger scribe.Emitter, projectPath, layerPath, cacheLayerPath string, launch, incremental bool) error {
+	err := installProcess.Execute(projectPath, layerPath, cacheLayerPath, launch)
+	if !incremental {
+		return err
+	}
+
+	if err == nil {
+		err = VerifyNodeModules(projectPath, filepath.Join(layerPath, "node_modules"), launch)
+		if err == nil {
+			return nil
+		}
+	}
+
+	logger.Subprocess("Incremental install failed (%s), falling back to a clean install", err)
+
+	err = resetNodeModules(projectPath, layerPath)
+	if err != nil {
+		return err
+	}
+
+	return installProcess.Execute(projectPath, layerPath, cacheLayerPath, launch)
+}
+
+// resetNodeModules empties the node_modules of a modules layer.
+func resetNodeModules(projectPath, layerPath string) error {
+	for _, name := range []string{"node_modules", "workspaces"} {
+		err := os.RemoveAll(filepath.Join(layerPath, name))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s directory: %w", name, err)
+		}
+	}
+
+	err := os.MkdirAll(filepath.Join(layerPath, "node_modules"), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create node_modules directory: %w", err)
+	}
+
+	manifest, err := ParsePackageManifest(projectPath)
+	if err != nil {
+		return err
+	}
+
+	workspaces, err := manifest.WorkspaceDirs(projectPath)
+	if err != nil {
+		return err
+	}
+
+	for _, workspace := range workspaces {
+		path := filepath.Join(projectPath, workspace, "node_modules")
+		info, err := os.Lstat(path)
+		if err == nil && info.IsDir() {
+			err = os.RemoveAll(path)
+			if err != nil {
+				return fmt.Errorf("failed to remove node_modules directory: %w", err)
+			}
+		}
+	}
+
+	projectNodeModules := filepath.Join(projectPath, "node_modules")
+	info, err := os.Lstat(projectNodeModules)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat node_modules directory: %w", err)
+	}
+
+	if info != nil && info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	err = os.RemoveAll(projectNodeModules)
+	if err != nil {
+		return fmt.Errorf("failed to remove node_modules directory: %w", err)
+	}
+
+	err = os.Symlink(filepath.Join(layerPath, "node_modules"), projectNodeModules)
+	if err != nil {
+		return fmt.Errorf("failed to symlink node_modules into working directory: %w", err)
+	}
+
+	return nil
+}
+
 func ensureNodeModulesSymlink(projectDir, targetLayer, tmpDir string) error {
 	projectDirNodeModules := filepath.Join(projectDir, "node_modules")
 	layerNodeModules := filepath.Join(targetLayer, "node_modules")
@@ -372,5 +931,110 @@ func ensureNodeModulesSymlink(projectDir, targetLayer, tmpDir string) error {
 		return err
 	}
 
-	return nil
+	return ensureWorkspaceNodeModulesSymlinks(projectDir, targetLayer, tmpDir)
+}
+
+// ensureWorkspaceNodeModulesSymlinks links the node_modules of each workspace.
+func ensureWorkspaceNodeModulesSymlinks(projectDir, targetLayer, tmpDir string) error {
+	workspacesDir := filepath.Join(targetLayer, "workspaces")
+
+	exists, err := fs.Exists(workspacesDir)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	return filepath.WalkDir(workspacesDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || entry.Name() != "node_modules" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(workspacesDir, path)
+		if err != nil {
+			return err
+		}
+
+		projectDirNodeModules := filepath.Join(projectDir, rel)
+		tmpNodeModules := filepath.Join(tmpDir, "workspaces", rel)
+
+		for _, d := range []string{projectDirNodeModules, tmpNodeModules} {
+			err = os.RemoveAll(d)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = os.MkdirAll(filepath.Dir(tmpNodeModules), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = os.Symlink(tmpNodeModules, projectDirNodeModules)
+		if err != nil {
+			return err
+		}
+
+		err = os.Symlink(path, tmpNodeModules)
+		if err != nil {
+			return err
+		}
+
+		return filepath.SkipDir
+	})
+}
+
+// ensurePnPCacheSymlink links the PnP cache folder to the layer.
+func ensurePnPCacheSymlink(projectDir string, config *YarnrcConfig, targetLayer, tmpDir string) error {
+	layerCache := filepath.Join(targetLayer, "cache")
+
+	exists, err := fs.Exists(layerCache)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	cacheFolder := ".yarn/cache"
+	if config != nil && config.CacheFolder != "" {
+		cacheFolder = config.CacheFolder
+	}
+
+	projectDirCache := filepath.Join(projectDir, filepath.FromSlash(cacheFolder))
+	info, err := os.Lstat(projectDirCache)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if info != nil && info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	tmpCache := filepath.Join(tmpDir, "yarn-cache")
+	for _, d := range []string{projectDirCache, tmpCache} {
+		err = os.RemoveAll(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(projectDirCache), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	err = os.Symlink(tmpCache, projectDirCache)
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(layerCache, tmpCache)
 }
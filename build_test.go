@@ -7,12 +7,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
-	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 
 	yarninstall "github.com/paketo-buildpacks/yarn-install"
@@ -75,7 +75,10 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		Expect(err).NotTo(HaveOccurred())
 
 		installProcess = &fakes.InstallProcess{}
-		installProcess.ShouldRunCall.Stub = func(string, map[string]interface{}) (bool, string, error) {
+		installProcess.CacheKeyCall.Stub = func(dir, version string) (map[string]string, error) {
+			return yarninstall.CacheKeyComponents(dir, homeDir, version)
+		}
+		installProcess.ShouldRunCall.Stub = func(string, string, map[string]interface{}) (bool, string, error) {
 			return true, "some-awesome-shasum", nil
 		}
 
@@ -86,17 +89,17 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		t.Setenv("BP_NODE_PROJECT_PATH", "some-project-dir")
 
 		sbomGenerator = &fakes.SBOMGenerator{}
-		sbomGenerator.GenerateCall.Returns.SBOM = sbom.SBOM{}
+		sbomGenerator.GenerateCall.Returns.LayerSBOM = yarninstall.LayerSBOM{}
 
 		configurationManager = &fakes.ConfigurationManager{}
 
-		configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+		configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
 			determinePathCalls = append(determinePathCalls, determinePathCallParams{
 				Typ:         typ,
 				Entry:       entry,
 				PlatformDir: platform,
 			})
-			return "", nil
+			return "", nil, nil
 		}
 		symlinker = &fakes.SymlinkManager{}
 		symlinker.LinkCall.Stub = func(o, n string) error {
@@ -162,7 +165,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(result.Layers)).To(Equal(1))
+			Expect(len(result.Layers)).To(Equal(4))
 
 			layer := result.Layers[0]
 			Expect(layer.Name).To(Equal("build-modules"))
@@ -174,10 +177,9 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			}))
 			Expect(layer.Build).To(BeTrue())
 			Expect(layer.Cache).To(BeTrue())
-			Expect(layer.Metadata).To(Equal(
-				map[string]interface{}{
-					"cache_sha": "some-awesome-shasum",
-				}))
+			Expect(layer.Metadata).To(HaveLen(2))
+			Expect(layer.Metadata).To(HaveKeyWithValue("cache_sha", "some-awesome-shasum"))
+			Expect(layer.Metadata).To(HaveKeyWithValue("cache_key", HaveKeyWithValue("arch", runtime.GOARCH)))
 
 			Expect(layer.SBOM.Formats()).To(HaveLen(3))
 
@@ -267,7 +269,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(len(layer.ExecD)).To(Equal(0))
 
-			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(2))
+			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(3))
 
 			Expect(determinePathCalls[0].Typ).To(Equal("npmrc"))
 			Expect(determinePathCalls[0].PlatformDir).To(Equal("some-platform-path"))
@@ -277,6 +279,10 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(determinePathCalls[1].PlatformDir).To(Equal("some-platform-path"))
 			Expect(determinePathCalls[1].Entry).To(Equal(".yarnrc"))
 
+			Expect(determinePathCalls[2].Typ).To(Equal("yarnrc-yml"))
+			Expect(determinePathCalls[2].PlatformDir).To(Equal("some-platform-path"))
+			Expect(determinePathCalls[2].Entry).To(Equal(".yarnrc.yml"))
+
 			Expect(symlinker.LinkCall.CallCount).To(BeZero())
 
 			Expect(installProcess.ShouldRunCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
@@ -287,9 +293,34 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(installProcess.ExecuteCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
 			Expect(installProcess.ExecuteCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "build-modules")))
+			Expect(installProcess.ExecuteCall.Receives.CacheLayerPath).To(Equal(filepath.Join(layersDir, "yarn-cache")))
 			Expect(installProcess.ExecuteCall.Receives.Launch).To(BeFalse())
 
-			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
+			cacheLayer := result.Layers[1]
+			Expect(cacheLayer.Name).To(Equal("yarn-cache"))
+			Expect(cacheLayer.Path).To(Equal(filepath.Join(layersDir, "yarn-cache")))
+			Expect(cacheLayer.Build).To(BeFalse())
+			Expect(cacheLayer.Launch).To(BeFalse())
+			Expect(cacheLayer.Cache).To(BeTrue())
+
+			sbomLayer := result.Layers[2]
+			Expect(sbomLayer.Name).To(Equal("modules-sbom"))
+			Expect(sbomLayer.Build).To(BeFalse())
+			Expect(sbomLayer.Launch).To(BeFalse())
+			Expect(sbomLayer.Cache).To(BeTrue())
+
+			Expect(filepath.Join(sbomLayer.Path, "build-modules", "sbom.cdx.json")).To(BeARegularFile())
+			Expect(filepath.Join(sbomLayer.Path, "build-modules", "sbom.spdx.json")).To(BeARegularFile())
+			Expect(filepath.Join(sbomLayer.Path, "build-modules", "sbom.syft.json")).To(BeARegularFile())
+
+			Expect(sbomGenerator.GenerateCall.Receives.ProjectPath).To(Equal(filepath.Join(workingDir, "some-project-dir")))
+			Expect(sbomGenerator.GenerateCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "build-modules")))
+
+			snapshotLayer := result.Layers[3]
+			Expect(snapshotLayer.Name).To(Equal("lockfile-snapshots"))
+			Expect(snapshotLayer.Build).To(BeFalse())
+			Expect(snapshotLayer.Launch).To(BeFalse())
+			Expect(snapshotLayer.Cache).To(BeTrue())
 		})
 	})
 
@@ -325,7 +356,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(result.Layers)).To(Equal(1))
+			Expect(len(result.Layers)).To(Equal(4))
 			layer := result.Layers[0]
 			Expect(layer.Name).To(Equal("launch-modules"))
 			Expect(layer.Path).To(Equal(filepath.Join(layersDir, "launch-modules")))
@@ -336,10 +367,9 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			}))
 			Expect(layer.Launch).To(BeTrue())
 			Expect(layer.Build).To(BeFalse())
-			Expect(layer.Metadata).To(Equal(
-				map[string]interface{}{
-					"cache_sha": "some-awesome-shasum",
-				}))
+			Expect(layer.Metadata).To(HaveLen(2))
+			Expect(layer.Metadata).To(HaveKeyWithValue("cache_sha", "some-awesome-shasum"))
+			Expect(layer.Metadata).To(HaveKeyWithValue("cache_key", HaveKeyWithValue("arch", runtime.GOARCH)))
 
 			Expect(layer.SBOM.Formats()).To(HaveLen(3))
 
@@ -427,7 +457,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				}
 			}`))
 
-			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(2))
+			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(3))
 
 			Expect(determinePathCalls[0].Typ).To(Equal("npmrc"))
 			Expect(determinePathCalls[0].PlatformDir).To(Equal("some-platform-path"))
@@ -437,6 +467,10 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(determinePathCalls[1].PlatformDir).To(Equal("some-platform-path"))
 			Expect(determinePathCalls[1].Entry).To(Equal(".yarnrc"))
 
+			Expect(determinePathCalls[2].Typ).To(Equal("yarnrc-yml"))
+			Expect(determinePathCalls[2].PlatformDir).To(Equal("some-platform-path"))
+			Expect(determinePathCalls[2].Entry).To(Equal(".yarnrc.yml"))
+
 			Expect(symlinker.LinkCall.CallCount).To(BeZero())
 
 			Expect(installProcess.ShouldRunCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
@@ -447,9 +481,11 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(installProcess.ExecuteCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
 			Expect(installProcess.ExecuteCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "launch-modules")))
+			Expect(installProcess.ExecuteCall.Receives.CacheLayerPath).To(Equal(filepath.Join(layersDir, "yarn-cache")))
 			Expect(installProcess.ExecuteCall.Receives.Launch).To(BeTrue())
 
-			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
+			Expect(sbomGenerator.GenerateCall.Receives.ProjectPath).To(Equal(filepath.Join(workingDir, "some-project-dir")))
+			Expect(sbomGenerator.GenerateCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "launch-modules")))
 
 			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", "node_modules"))
 			Expect(err).NotTo(HaveOccurred())
@@ -461,9 +497,19 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("when not required during either build or launch", func() {
-		it("returns a result that has no layers", func() {
-			result, err := build(packit.BuildContext{
+	context("when the install process places workspace node_modules in the layer", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "some-project-dir", "packages", "a"), os.ModePerm)).To(Succeed())
+
+			installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _ string, _ bool) error {
+				return os.MkdirAll(filepath.Join(modulesLayerPath, "workspaces", "packages", "a", "node_modules"), os.ModePerm)
+			}
+		})
+
+		it("links each workspace node_modules through the tmp dir", func() {
+			_, err := build(packit.BuildContext{
 				BuildpackInfo: packit.BuildpackInfo{
 					Name:        "Some Buildpack",
 					Version:     "1.2.3",
@@ -474,50 +520,35 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				Layers:     packit.Layers{Path: layersDir},
 				Plan: packit.BuildpackPlan{
 					Entries: []packit.BuildpackPlanEntry{
-						{
-							Name: "node_modules",
-							Metadata: map[string]interface{}{
-								"build": true,
-							},
-						},
+						{Name: "node_modules"},
 					},
 				},
-				Stack: "some-stack",
 				Platform: packit.Platform{
 					Path: "some-platform-path",
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal(packit.BuildResult{}))
-		})
-	})
 
-	context("when required during both build or launch", func() {
-		type setupModulesParams struct {
-			WorkingDir              string
-			CurrentModulesLayerPath string
-			NextModulesLayerPath    string
-			TempDir                 string
-		}
+			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", "packages", "a", "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "workspaces", "packages", "a", "node_modules")))
 
-		var setupModulesCalls []setupModulesParams
+			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "workspaces", "packages", "a", "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "launch-modules", "workspaces", "packages", "a", "node_modules")))
+		})
+	})
 
+	context("when the Yarn version signals conflict", func() {
 		it.Before(func() {
-			entryResolver.MergeLayerTypesCall.Returns.Launch = true
 			entryResolver.MergeLayerTypesCall.Returns.Build = true
-			t.Setenv("BP_NODE_PROJECT_PATH", "")
 
-			installProcess.SetupModulesCall.Stub = func(w string, c string, n string) (string, error) {
-				setupModulesCalls = append(setupModulesCalls, setupModulesParams{
-					WorkingDir:              w,
-					CurrentModulesLayerPath: c,
-					NextModulesLayerPath:    n,
-				})
-				return n, nil
-			}
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte("# yarn lockfile v1\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", ".yarnrc.yml"), []byte("enableTelemetry: false\n"), 0600)).To(Succeed())
 		})
-		it("returns a result that has both layers and the module setup updates accordingly", func() {
-			result, err := build(packit.BuildContext{
+
+		it("logs which signal was used", func() {
+			_, err := build(packit.BuildContext{
 				BuildpackInfo: packit.BuildpackInfo{
 					Name:        "Some Buildpack",
 					Version:     "1.2.3",
@@ -528,112 +559,144 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				Layers:     packit.Layers{Path: layersDir},
 				Plan: packit.BuildpackPlan{
 					Entries: []packit.BuildpackPlanEntry{
-						{
-							Name: "node_modules",
-							Metadata: map[string]interface{}{
-								"build": true,
-							},
-						},
+						{Name: "node_modules"},
 					},
 				},
-				Stack: "some-stack",
 				Platform: packit.Platform{
 					Path: "some-platform-path",
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			launchLayer := result.Layers[1]
-			Expect(launchLayer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "setup-symlinks")}))
-			Expect(len(result.Layers)).To(Equal(2))
-
-			Expect(installProcess.SetupModulesCall.CallCount).To(Equal(2))
-
-			Expect(setupModulesCalls[0].WorkingDir).To(Equal(workingDir))
-			Expect(setupModulesCalls[0].CurrentModulesLayerPath).To(Equal(""))
-			Expect(setupModulesCalls[0].NextModulesLayerPath).To(Equal(result.Layers[0].Path))
-
-			Expect(setupModulesCalls[1].WorkingDir).To(Equal(workingDir))
-			Expect(setupModulesCalls[1].CurrentModulesLayerPath).To(Equal(result.Layers[0].Path))
-			Expect(setupModulesCalls[1].NextModulesLayerPath).To(Equal(result.Layers[1].Path))
-
-			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "node_modules"))
-			Expect(err).NotTo(HaveOccurred())
-			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "node_modules")))
-
-			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
-			Expect(err).NotTo(HaveOccurred())
-			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "build-modules", "node_modules")))
+			Expect(buffer.String()).To(ContainSubstring("Detected Yarn classic, providing node_modules"))
+			Expect(buffer.String()).To(ContainSubstring("Conflicting Yarn version signals: using yarn.lock format (classic) over .yarnrc.yml (berry)"))
+			Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
 		})
 	})
 
-	context("when re-using previous modules layer", func() {
+	context("when the yarn version is determined", func() {
+		var (
+			binDir   string
+			buildCtx packit.BuildContext
+		)
+
 		it.Before(func() {
-			installProcess.ShouldRunCall.Stub = nil
-			installProcess.ShouldRunCall.Returns.Run = false
-			entryResolver.MergeLayerTypesCall.Returns.Launch = true
 			entryResolver.MergeLayerTypesCall.Returns.Build = true
-		})
 
-		it("does not redo the build process", func() {
-			result, err := build(packit.BuildContext{
-				BuildpackInfo: packit.BuildpackInfo{
-					Name:        "Some Buildpack",
-					Version:     "1.2.3",
-					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
-				},
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte("# yarn lockfile v1\n"), 0600)).To(Succeed())
+
+			var err error
+			binDir, err = os.MkdirTemp("", "bin")
+			Expect(err).NotTo(HaveOccurred())
+			t.Setenv("PATH", binDir)
+
+			buildCtx = packit.BuildContext{
 				WorkingDir: workingDir,
 				CNBPath:    cnbDir,
 				Layers:     packit.Layers{Path: layersDir},
 				Plan: packit.BuildpackPlan{
 					Entries: []packit.BuildpackPlanEntry{
-						{
-							Name: "node_modules",
-							Metadata: map[string]interface{}{
-								"build": true,
-							},
-						},
+						{Name: "node_modules"},
 					},
 				},
-				Stack: "some-stack",
 				Platform: packit.Platform{
 					Path: "some-platform-path",
 				},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(binDir)).To(Succeed())
+		})
+
+		context("when the project pins its yarn version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "package.json"), []byte(`{"packageManager": "yarn@1.22.19"}`), 0600)).To(Succeed())
 			})
-			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(result.Layers)).To(Equal(2))
-			buildLayer := result.Layers[0]
-			Expect(buildLayer.Name).To(Equal("build-modules"))
-			Expect(buildLayer.Path).To(Equal(filepath.Join(layersDir, "build-modules")))
-			Expect(buildLayer.Build).To(BeTrue())
-			Expect(buildLayer.Cache).To(BeTrue())
+			it("passes the pinned version to the install process", func() {
+				_, err := build(buildCtx)
+				Expect(err).NotTo(HaveOccurred())
 
-			launchLayer := result.Layers[1]
-			Expect(launchLayer.Name).To(Equal("launch-modules"))
-			Expect(launchLayer.Path).To(Equal(filepath.Join(layersDir, "launch-modules")))
-			Expect(launchLayer.Launch).To(BeTrue())
+				Expect(installProcess.CacheKeyCall.Receives.YarnVersion).To(Equal("1.22.19"))
+				Expect(installProcess.ShouldRunCall.Receives.YarnVersion).To(Equal("1.22.19"))
+			})
+		})
 
-			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", "node_modules"))
-			Expect(err).NotTo(HaveOccurred())
-			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "node_modules")))
+		context("when the project does not pin its yarn version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(binDir, "yarn"), []byte(`#!/bin/sh
+echo "some-warning" >&2
+echo "1.22.22"
+`), 0755)).To(Succeed())
+			})
 
-			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
-			Expect(err).NotTo(HaveOccurred())
-			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "build-modules", "node_modules")))
+			it("passes the version that yarn prints to the install process", func() {
+				_, err := build(buildCtx)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.CacheKeyCall.Receives.YarnVersion).To(Equal("1.22.22"))
+				Expect(installProcess.ShouldRunCall.Receives.YarnVersion).To(Equal("1.22.22"))
+			})
+		})
 
+		context("when yarn cannot be executed", func() {
+			it("leaves the version out of the cache key", func() {
+				_, err := build(buildCtx)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Failed to determine the yarn version"))
+				Expect(installProcess.CacheKeyCall.Receives.YarnVersion).To(Equal(""))
+				Expect(installProcess.ShouldRunCall.Receives.YarnVersion).To(Equal(""))
+			})
 		})
 	})
 
-	context("when re-using previous launch modules layer", func() {
+	context("when a yarnrc-yml service binding is provided", func() {
+		var (
+			bindingDir     string
+			installYarnrcs []string
+		)
+
 		it.Before(func() {
-			installProcess.ShouldRunCall.Stub = nil
-			installProcess.ShouldRunCall.Returns.Run = false
-			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			var err error
+			bindingDir, err = os.MkdirTemp("", "binding")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(bindingDir, ".yarnrc.yml"), []byte(`npmScopes:
+  private:
+    npmRegistryServer: https://registry.example.com
+    npmAuthToken: some-token
+`), 0600)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(homeDir, ".yarnrc.yml"), []byte("enableTelemetry: false\n"), 0644)).To(Succeed())
+
+			configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+				if typ == "yarnrc-yml" {
+					return filepath.Join(bindingDir, entry), nil, nil
+				}
+				return "", nil, nil
+			}
+
+			installYarnrcs = nil
+			installProcess.ExecuteCall.Stub = func(string, string, string, bool) error {
+				content, err := os.ReadFile(filepath.Join(homeDir, ".yarnrc.yml"))
+				if err != nil {
+					return err
+				}
+				installYarnrcs = append(installYarnrcs, string(content))
+				return nil
+			}
 		})
 
-		it("does not redo the build process", func() {
-			result, err := build(packit.BuildContext{
+		it.After(func() {
+			Expect(os.RemoveAll(bindingDir)).To(Succeed())
+		})
+
+		it("merges the binding into ~/.yarnrc.yml during the install and restores it afterwards", func() {
+			_, err := build(packit.BuildContext{
 				BuildpackInfo: packit.BuildpackInfo{
 					Name:        "Some Buildpack",
 					Version:     "1.2.3",
@@ -644,71 +707,73 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				Layers:     packit.Layers{Path: layersDir},
 				Plan: packit.BuildpackPlan{
 					Entries: []packit.BuildpackPlanEntry{
-						{
-							Name: "node_modules",
-							Metadata: map[string]interface{}{
-								"build": true,
-							},
-						},
+						{Name: "node_modules"},
 					},
 				},
-				Stack: "some-stack",
 				Platform: packit.Platform{
 					Path: "some-platform-path",
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(result.Layers)).To(Equal(1))
-			launchLayer := result.Layers[0]
-			Expect(launchLayer.Name).To(Equal("launch-modules"))
-			Expect(launchLayer.Path).To(Equal(filepath.Join(layersDir, "launch-modules")))
-			Expect(launchLayer.Launch).To(BeTrue())
-
-			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", "node_modules"))
-			Expect(err).NotTo(HaveOccurred())
-			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "node_modules")))
+			Expect(installYarnrcs).To(HaveLen(1))
+			Expect(installYarnrcs[0]).To(MatchYAML(`enableTelemetry: false
+npmScopes:
+  private:
+    npmRegistryServer: https://registry.example.com
+    npmAuthToken: some-token
+`))
 
-			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			content, err := os.ReadFile(filepath.Join(homeDir, ".yarnrc.yml"))
 			Expect(err).NotTo(HaveOccurred())
-			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "launch-modules", "node_modules")))
-
+			Expect(string(content)).To(Equal("enableTelemetry: false\n"))
 		})
-	})
-
-	context("failure cases", func() {
 
-		context("when the project path parser provided fails", func() {
+		context("when the install fails", func() {
 			it.Before(func() {
-				t.Setenv("BP_NODE_PROJECT_PATH", "does_not_exist")
+				installProcess.ExecuteCall.Stub = func(string, string, string, bool) error {
+					return errors.New("failed to execute install process")
+				}
 			})
 
-			it("returns an error", func() {
+			it("still restores ~/.yarnrc.yml", func() {
 				_, err := build(packit.BuildContext{
 					WorkingDir: workingDir,
-					Layers:     packit.Layers{Path: layersDir},
 					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
 					Plan: packit.BuildpackPlan{
 						Entries: []packit.BuildpackPlanEntry{
 							{Name: "node_modules"},
 						},
 					},
+					Platform: packit.Platform{
+						Path: "some-platform-path",
+					},
 				})
-				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("could not find project path \"%s/does_not_exist\": stat %s/does_not_exist: no such file or directory", workingDir, workingDir))))
+				Expect(err).To(MatchError("failed to execute install process"))
+
+				content, err := os.ReadFile(filepath.Join(homeDir, ".yarnrc.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("enableTelemetry: false\n"))
 			})
 		})
 
-		context("when determining the path for the npmrc fails", func() {
+		context("when ~/.yarnrc.yml cannot be restored", func() {
 			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "npmrc" {
-						return "", errors.New("failed to determine path for npmrc")
+				installProcess.ExecuteCall.Stub = func(string, string, string, bool) error {
+					err := os.Remove(filepath.Join(homeDir, ".yarnrc.yml"))
+					if err != nil {
+						return err
 					}
-					return "", nil
+					return os.Chmod(homeDir, 0500)
 				}
 			})
 
-			it("errors", func() {
+			it.After(func() {
+				Expect(os.Chmod(homeDir, os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
 				_, err := build(packit.BuildContext{
 					WorkingDir: workingDir,
 					CNBPath:    cnbDir,
@@ -718,54 +783,52 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 							{Name: "node_modules"},
 						},
 					},
+					Platform: packit.Platform{
+						Path: "some-platform-path",
+					},
 				})
-				Expect(err).To(MatchError("failed to determine path for npmrc"))
+				Expect(err).To(MatchError(ContainSubstring("failed to restore ~/.yarnrc.yml")))
 			})
 		})
+	})
 
-		context("when determining the path for the yarnrc fails", func() {
-			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "yarnrc" {
-						return "", errors.New("failed to determine path for yarnrc")
-					}
-					return "", nil
-				}
-			})
+	context("when the configuration files were written for the build", func() {
+		var cleanedUp []string
 
-			it("errors", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Layers:     packit.Layers{Path: layersDir},
-					Plan: packit.BuildpackPlan{
-						Entries: []packit.BuildpackPlanEntry{
-							{Name: "node_modules"},
-						},
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			cleanedUp = nil
+			configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+				return "", func() error {
+					cleanedUp = append(cleanedUp, typ)
+					return nil
+				}, nil
+			}
+		})
+
+		it("removes them once the build is done", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
 					},
-				})
-				Expect(err).To(MatchError("failed to determine path for yarnrc"))
+				},
 			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cleanedUp).To(Equal([]string{"npmrc", "yarnrc", "yarnrc-yml"}))
 		})
 
-		context("when .npmrc service binding symlink cannot be created", func() {
+		context("when the install fails", func() {
 			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "npmrc" {
-						return "some-path/.npmrc", nil
-					}
-					return "", nil
-				}
-
-				symlinker.LinkCall.Stub = func(o string, n string) error {
-					if strings.Contains(o, ".npmrc") {
-						return errors.New("symlinking .npmrc error")
-					}
-					return nil
-				}
+				installProcess.ExecuteCall.Returns.Error = errors.New("failed to execute install process")
 			})
 
-			it("errors", func() {
+			it("still removes them", func() {
 				_, err := build(packit.BuildContext{
 					WorkingDir: workingDir,
 					CNBPath:    cnbDir,
@@ -776,28 +839,22 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 						},
 					},
 				})
-				Expect(err).To(MatchError(ContainSubstring("symlinking .npmrc error")))
+				Expect(err).To(MatchError("failed to execute install process"))
+
+				Expect(cleanedUp).To(Equal([]string{"npmrc", "yarnrc", "yarnrc-yml"}))
 			})
 		})
 
-		context("when .yarnrc service binding symlink cannot be created", func() {
+		context("when they cannot be removed", func() {
 			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "yarnrc" {
-						return "some-path/.yarnrc", nil
-					}
-					return "", nil
-				}
-
-				symlinker.LinkCall.Stub = func(o string, n string) error {
-					if strings.Contains(o, ".yarnrc") {
-						return errors.New("symlinking .yarnrc error")
-					}
-					return nil
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+					return "", func() error {
+						return errors.New("failed to remove merged bindings")
+					}, nil
 				}
 			})
 
-			it("errors", func() {
+			it("returns an error", func() {
 				_, err := build(packit.BuildContext{
 					WorkingDir: workingDir,
 					CNBPath:    cnbDir,
@@ -808,16 +865,1128 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 						},
 					},
 				})
-				Expect(err).To(MatchError(ContainSubstring("symlinking .yarnrc error")))
+				Expect(err).To(MatchError("failed to remove merged bindings"))
 			})
 		})
+	})
 
-		context("during the build installation process", func() {
-			it.Before(func() {
-				entryResolver.MergeLayerTypesCall.Returns.Build = true
-			})
-			context("when the layer cannot be retrieved", func() {
-				it.Before(func() {
+	context("when the project uses Plug'n'Play", func() {
+		var (
+			binDir      string
+			invocations string
+			buildCtx    packit.BuildContext
+		)
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			projectDir := filepath.Join(workingDir, "some-project-dir")
+			Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{"dependencies": {"leftpad": "~0.0.1"}}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(projectDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("__metadata:\n  version: 8\n  cacheKey: 10c0\n"), 0600)).To(Succeed())
+
+			var err error
+			binDir, err = os.MkdirTemp("", "bin")
+			Expect(err).NotTo(HaveOccurred())
+
+			// Berry projects are installed by the yarn on the PATH, which
+			// writes the Plug'n'Play artifacts into the project
+			invocations = filepath.Join(binDir, "invocations")
+			Expect(os.WriteFile(filepath.Join(binDir, "yarn"), []byte(`#!/bin/sh
+echo "$@" >> `+invocations+`
+case "$1" in
+  --version) echo "4.0.2" ;;
+  plugin) echo '{"name":"@yarnpkg/plugin-workspace-tools"}' ;;
+  *)
+    echo "module.exports = {}" > .pnp.cjs
+    mkdir -p .yarn/unplugged/leftpad-npm-0.0.1-787440a011/node_modules/leftpad
+    mkdir -p "$YARN_CACHE_FOLDER"
+    touch "$YARN_CACHE_FOLDER/leftpad-npm-0.0.1-787440a011-10c0.zip"
+    ;;
+esac
+`), 0755)).To(Succeed())
+			t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+			buildCtx = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "yarn_pkgs"},
+					},
+				},
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(binDir)).To(Succeed())
+		})
+
+		it("installs the packages into a cached launch layer and loads the PnP runtime at launch", func() {
+			result, err := build(buildCtx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(4))
+			launchLayer := result.Layers[0]
+			Expect(launchLayer.Name).To(Equal("launch-modules"))
+			Expect(launchLayer.Launch).To(BeTrue())
+			Expect(launchLayer.Cache).To(BeTrue())
+			Expect(launchLayer.LaunchEnv).To(HaveKeyWithValue("NODE_OPTIONS.append", "--require "+filepath.Join(workingDir, "some-project-dir", ".pnp.cjs")))
+			Expect(launchLayer.LaunchEnv).To(HaveKeyWithValue("NODE_OPTIONS.delim", " "))
+
+			Expect(filepath.Join(launchLayer.Path, "pnp", ".pnp.cjs")).To(BeARegularFile())
+			Expect(filepath.Join(launchLayer.Path, "pnp", ".yarn", "unplugged", "leftpad-npm-0.0.1-787440a011")).To(BeADirectory())
+			Expect(filepath.Join(launchLayer.Path, "cache", "leftpad-npm-0.0.1-787440a011-10c0.zip")).To(BeARegularFile())
+
+			projectLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", ".yarn", "cache"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(projectLink).To(Equal(filepath.Join(tmpDir, "yarn-cache")))
+
+			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "yarn-cache"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpLink).To(Equal(filepath.Join(launchLayer.Path, "cache")))
+		})
+
+		context("when the launch layer is reused", func() {
+			it.Before(func() {
+				result, err := build(buildCtx)
+				Expect(err).NotTo(HaveOccurred())
+
+				// Only the layer, restored from the cache, holds the artifacts
+				// of the previous install
+				Expect(os.WriteFile(filepath.Join(layersDir, "launch-modules.toml"), []byte(fmt.Sprintf("[metadata]\n  cache_sha = %q\n", result.Layers[0].Metadata["cache_sha"])), 0600)).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(workingDir, "some-project-dir", ".pnp.cjs"))).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(workingDir, "some-project-dir", ".yarn"))).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(tmpDir, "yarn-cache"))).To(Succeed())
+				Expect(os.Remove(invocations)).To(Succeed())
+			})
+
+			it("restores the PnP artifacts and links the cache without installing", func() {
+				result, err := build(buildCtx)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+
+				content, err := os.ReadFile(invocations)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("--version\n"))
+
+				launchLayer := result.Layers[0]
+				Expect(launchLayer.Name).To(Equal("launch-modules"))
+				Expect(launchLayer.Cache).To(BeTrue())
+
+				Expect(filepath.Join(workingDir, "some-project-dir", ".pnp.cjs")).To(BeARegularFile())
+				Expect(filepath.Join(workingDir, "some-project-dir", ".yarn", "unplugged", "leftpad-npm-0.0.1-787440a011")).To(BeADirectory())
+
+				projectLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", ".yarn", "cache"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(projectLink).To(Equal(filepath.Join(tmpDir, "yarn-cache")))
+
+				tmpLink, err := os.Readlink(filepath.Join(tmpDir, "yarn-cache"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tmpLink).To(Equal(filepath.Join(launchLayer.Path, "cache")))
+			})
+		})
+	})
+
+	context("when not required during either build or launch", func() {
+		it("returns a result that has no layers", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{
+							Name: "node_modules",
+							Metadata: map[string]interface{}{
+								"build": true,
+							},
+						},
+					},
+				},
+				Stack: "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(packit.BuildResult{}))
+		})
+	})
+
+	context("when required during both build or launch", func() {
+		type setupModulesParams struct {
+			WorkingDir              string
+			CurrentModulesLayerPath string
+			NextModulesLayerPath    string
+			TempDir                 string
+		}
+
+		var setupModulesCalls []setupModulesParams
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+			t.Setenv("BP_NODE_PROJECT_PATH", "")
+
+			installProcess.SetupModulesCall.Stub = func(w string, c string, n string) (string, error) {
+				setupModulesCalls = append(setupModulesCalls, setupModulesParams{
+					WorkingDir:              w,
+					CurrentModulesLayerPath: c,
+					NextModulesLayerPath:    n,
+				})
+				return n, nil
+			}
+		})
+		it("returns a result that has both layers and the module setup updates accordingly", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{
+							Name: "node_modules",
+							Metadata: map[string]interface{}{
+								"build": true,
+							},
+						},
+					},
+				},
+				Stack: "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			launchLayer := result.Layers[1]
+			Expect(launchLayer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "setup-symlinks")}))
+			Expect(len(result.Layers)).To(Equal(5))
+
+			Expect(installProcess.SetupModulesCall.CallCount).To(Equal(2))
+
+			Expect(setupModulesCalls[0].WorkingDir).To(Equal(workingDir))
+			Expect(setupModulesCalls[0].CurrentModulesLayerPath).To(Equal(""))
+			Expect(setupModulesCalls[0].NextModulesLayerPath).To(Equal(result.Layers[0].Path))
+
+			Expect(setupModulesCalls[1].WorkingDir).To(Equal(workingDir))
+			Expect(setupModulesCalls[1].CurrentModulesLayerPath).To(Equal(result.Layers[0].Path))
+			Expect(setupModulesCalls[1].NextModulesLayerPath).To(Equal(result.Layers[1].Path))
+
+			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "node_modules")))
+
+			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "build-modules", "node_modules")))
+		})
+	})
+
+	context("when re-using previous modules layer", func() {
+		it.Before(func() {
+			installProcess.ShouldRunCall.Stub = nil
+			installProcess.ShouldRunCall.Returns.Run = false
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+		})
+
+		it("does not redo the build process", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{
+							Name: "node_modules",
+							Metadata: map[string]interface{}{
+								"build": true,
+							},
+						},
+					},
+				},
+				Stack: "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(len(result.Layers)).To(Equal(5))
+			buildLayer := result.Layers[0]
+			Expect(buildLayer.Name).To(Equal("build-modules"))
+			Expect(buildLayer.Path).To(Equal(filepath.Join(layersDir, "build-modules")))
+			Expect(buildLayer.Build).To(BeTrue())
+			Expect(buildLayer.Cache).To(BeTrue())
+
+			launchLayer := result.Layers[1]
+			Expect(launchLayer.Name).To(Equal("launch-modules"))
+			Expect(launchLayer.Path).To(Equal(filepath.Join(layersDir, "launch-modules")))
+			Expect(launchLayer.Launch).To(BeTrue())
+
+			// Neither layer was restored, so the SBOMs of the previous image
+			// are kept
+			Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(0))
+			Expect(buildLayer.SBOM).To(BeNil())
+			Expect(launchLayer.SBOM).To(BeNil())
+
+			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "node_modules")))
+
+			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "build-modules", "node_modules")))
+
+		})
+
+		context("when the SBOMs of the layers were persisted", func() {
+			it.Before(func() {
+				for _, name := range []string{"build-modules", "launch-modules"} {
+					dir := filepath.Join(layersDir, "modules-sbom", name)
+					Expect(os.MkdirAll(dir, os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(dir, "sbom.cdx.json"), []byte(`{"layer": "`+name+`"}`), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(dir, "index.json"), []byte(`{
+						"key": "some-awesome-shasum",
+						"formats": {"application/vnd.cyclonedx+json": "sbom.cdx.json"}
+					}`), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(layersDir, name+".toml"), []byte("[metadata]\n  cache_sha = \"some-awesome-shasum\"\n"), 0600)).To(Succeed())
+				}
+			})
+
+			it("reuses them", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:        "Some Buildpack",
+						Version:     "1.2.3",
+						SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring("Reusing SBOM of the cached layer"))
+
+				for i, name := range []string{"build-modules", "launch-modules"} {
+					formats := result.Layers[i].SBOM.Formats()
+					Expect(formats).To(HaveLen(1))
+					Expect(formats[0].Extension).To(Equal("cdx.json"))
+
+					content, err := io.ReadAll(formats[0].Content)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(MatchJSON(`{"layer": "` + name + `"}`))
+				}
+			})
+
+			context("when they describe another install of the layers", func() {
+				it.Before(func() {
+					for _, name := range []string{"build-modules", "launch-modules"} {
+						Expect(os.WriteFile(filepath.Join(layersDir, name+".toml"), []byte("[metadata]\n  cache_sha = \"some-other-shasum\"\n"), 0600)).To(Succeed())
+					}
+					Expect(os.MkdirAll(filepath.Join(layersDir, "build-modules", "node_modules"), os.ModePerm)).To(Succeed())
+				})
+
+				it("generates the SBOM of the restored layer again", func() {
+					result, err := build(packit.BuildContext{
+						BuildpackInfo: packit.BuildpackInfo{
+							Name:        "Some Buildpack",
+							Version:     "1.2.3",
+							SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+						},
+						WorkingDir: workingDir,
+						CNBPath:    cnbDir,
+						Layers:     packit.Layers{Path: layersDir},
+						Plan: packit.BuildpackPlan{
+							Entries: []packit.BuildpackPlanEntry{
+								{Name: "node_modules"},
+							},
+						},
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+					Expect(sbomGenerator.GenerateCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "build-modules")))
+					Expect(result.Layers[0].SBOM.Formats()).To(HaveLen(1))
+					Expect(result.Layers[1].SBOM).To(BeNil())
+
+					content, err := os.ReadFile(filepath.Join(layersDir, "modules-sbom", "build-modules", "index.json"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(ContainSubstring(`"key":"some-other-shasum"`))
+				})
+			})
+		})
+	})
+
+	context("when re-using previous launch modules layer", func() {
+		it.Before(func() {
+			installProcess.ShouldRunCall.Stub = nil
+			installProcess.ShouldRunCall.Returns.Run = false
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+		})
+
+		it("does not redo the build process", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{
+							Name: "node_modules",
+							Metadata: map[string]interface{}{
+								"build": true,
+							},
+						},
+					},
+				},
+				Stack: "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(len(result.Layers)).To(Equal(4))
+			launchLayer := result.Layers[0]
+			Expect(launchLayer.Name).To(Equal("launch-modules"))
+			Expect(launchLayer.Path).To(Equal(filepath.Join(layersDir, "launch-modules")))
+			Expect(launchLayer.Launch).To(BeTrue())
+
+			workspaceLink, err := os.Readlink(filepath.Join(workingDir, "some-project-dir", "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workspaceLink).To(Equal(filepath.Join(tmpDir, "node_modules")))
+
+			tmpLink, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpLink).To(Equal(filepath.Join(layersDir, "launch-modules", "node_modules")))
+
+		})
+	})
+
+	context("when the cached layer was installed from other inputs", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			projectDir := filepath.Join(workingDir, "some-project-dir")
+			Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("# yarn lockfile v1\n\nleftpad@~0.0.1:\n  version \"0.0.2\"\n"), 0600)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), []byte(`[metadata]
+cache_sha = "some-old-shasum"
+
+lockfile_sha = "sha256:8fc440f72596db3c60a574c3e8f4a0035dd94dfbfc82a7be899c33715360d0fd"
+
+[metadata.cache_key]
+"yarn.lock" = "sha256:some-old-sum"
+`), 0600)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(layersDir, "lockfile-snapshots"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layersDir, "lockfile-snapshots", "build-modules.json"), []byte(`{"leftpad":["0.0.1"]}`), 0600)).To(Succeed())
+		})
+
+		it("logs which inputs and dependencies changed and records the new lockfile", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "1.2.3",
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installProcess.CacheKeyCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
+
+			Expect(buffer.String()).To(ContainSubstring("Cached layer is out of date:"))
+			Expect(buffer.String()).To(ContainSubstring("yarn.lock changed (~1 upgraded)"))
+			Expect(buffer.String()).To(ContainSubstring("architecture none → " + runtime.GOARCH))
+
+			Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("cache_key", HaveKeyWithValue("yarn.lock", MatchRegexp(`^sha256:[0-9a-f]{64}$`))))
+			Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("lockfile_sha", MatchRegexp(`^sha256:[0-9a-f]{64}$`)))
+			Expect(result.Layers[0].Metadata).NotTo(HaveKey("lockfile"))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "lockfile-snapshots", "build-modules.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{"leftpad": ["0.0.2"]}`))
+
+			Expect(buffer.String()).To(ContainSubstring("Dependency changes since the cached layer:"))
+			Expect(buffer.String()).To(ContainSubstring("Upgraded (1)"))
+			Expect(buffer.String()).To(ContainSubstring("leftpad 0.0.1 → 0.0.2"))
+
+			content, err = os.ReadFile(filepath.Join(layersDir, "build-modules", "dependency-changes.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"upgraded": [`))
+		})
+
+		context("when the yarn.lock cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+			})
+
+			it("skips the snapshot and the dependency changes report", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "1.2.3",
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Failed to parse yarn.lock"))
+				Expect(result.Layers[0].Metadata).NotTo(HaveKey("lockfile_sha"))
+				Expect(filepath.Join(layersDir, "build-modules", "dependency-changes.json")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("when the modules are only required during launch", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Build = false
+				entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+				// Only the metadata of a launch layer that is not cached is
+				// restored, its snapshot is kept in a cache-only layer
+				Expect(os.Rename(filepath.Join(layersDir, "build-modules.toml"), filepath.Join(layersDir, "launch-modules.toml"))).To(Succeed())
+				Expect(os.Rename(filepath.Join(layersDir, "lockfile-snapshots", "build-modules.json"), filepath.Join(layersDir, "lockfile-snapshots", "launch-modules.json"))).To(Succeed())
+			})
+
+			it("reports the dependency changes from the saved snapshot", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "1.2.3",
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Name).To(Equal("launch-modules"))
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("lockfile_sha", MatchRegexp(`^sha256:[0-9a-f]{64}$`)))
+				Expect(result.Layers[0].Metadata).NotTo(HaveKey("lockfile"))
+
+				Expect(buffer.String()).To(ContainSubstring("yarn.lock changed (~1 upgraded)"))
+				Expect(buffer.String()).To(ContainSubstring("leftpad 0.0.1 → 0.0.2"))
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "launch-modules", "dependency-changes.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"upgraded": [`))
+			})
+		})
+	})
+
+	context("when the launch modules can be pruned from the build modules", func() {
+		var nodeHome string
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			projectDir := filepath.Join(workingDir, "some-project-dir")
+			Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{
+				"dependencies": {"leftpad": "~0.0.1"},
+				"devDependencies": {"jest": "^29.0.0"}
+			}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("# yarn lockfile v1\n\njest@^29.0.0:\n  version \"29.7.0\"\n\nleftpad@~0.0.1:\n  version \"0.0.1\"\n"), 0600)).To(Succeed())
+
+			var err error
+			nodeHome, err = os.MkdirTemp("", "node-home")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.MkdirAll(filepath.Join(nodeHome, "include", "node"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(nodeHome, "include", "node", "node_version.h"), []byte("#define NODE_MODULE_VERSION 115\n"), 0600)).To(Succeed())
+			t.Setenv("NODE_HOME", nodeHome)
+
+			installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _ string, _ bool) error {
+				for name, version := range map[string]string{"jest": "29.7.0", "leftpad": "0.0.1"} {
+					err := os.MkdirAll(filepath.Join(modulesLayerPath, "node_modules", name), os.ModePerm)
+					if err != nil {
+						return err
+					}
+
+					err = os.WriteFile(filepath.Join(modulesLayerPath, "node_modules", name, "package.json"), []byte(`{"version": "`+version+`"}`), 0600)
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(nodeHome)).To(Succeed())
+		})
+
+		it("prunes the devDependencies instead of running a second install", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "1.2.3",
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+			Expect(installProcess.ExecuteCall.Receives.Launch).To(BeFalse())
+			Expect(installProcess.SetupModulesCall.CallCount).To(Equal(1))
+
+			Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("cache_sha", "some-awesome-shasum"))
+			Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("node_abi", "115"))
+
+			launchModules := filepath.Join(layersDir, "launch-modules", "node_modules")
+			Expect(filepath.Join(launchModules, "leftpad")).To(BeADirectory())
+			Expect(filepath.Join(launchModules, "jest")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(layersDir, "build-modules", "node_modules", "jest")).To(BeADirectory())
+
+			Expect(buffer.String()).To(MatchRegexp(`Copied node_modules from .*build-modules \((reflink|hardlink|copy)\) in`))
+			Expect(buffer.String()).To(ContainSubstring("Pruning devDependencies from the build modules"))
+			Expect(buffer.String()).To(ContainSubstring("Removed 1 package(s)"))
+		})
+
+		context("when the build modules cannot be pruned", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+			})
+
+			it("falls back to a production install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "1.2.3",
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Pruning devDependencies failed"))
+				Expect(buffer.String()).To(ContainSubstring("falling back to a production install"))
+
+				Expect(installProcess.SetupModulesCall.CallCount).To(Equal(2))
+				Expect(installProcess.SetupModulesCall.Receives.NextModulesLayerPath).To(Equal(filepath.Join(layersDir, "launch-modules")))
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(2))
+				Expect(installProcess.ExecuteCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "launch-modules")))
+				Expect(installProcess.ExecuteCall.Receives.Launch).To(BeTrue())
+			})
+		})
+
+		context("when the build modules are reused", func() {
+			it.Before(func() {
+				installProcess.ShouldRunCall.Stub = func(_, _ string, metadata map[string]interface{}) (bool, string, error) {
+					return metadata["cache_sha"] != "some-awesome-shasum", "some-awesome-shasum", nil
+				}
+
+				Expect(os.MkdirAll(filepath.Join(layersDir, "build-modules", "node_modules"), os.ModePerm)).To(Succeed())
+				Expect(installProcess.ExecuteCall.Stub("", filepath.Join(layersDir, "build-modules"), "", false)).To(Succeed())
+			})
+
+			context("and were installed for the current node ABI", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), []byte("[metadata]\ncache_sha = \"some-awesome-shasum\"\nnode_abi = \"115\"\n"), 0600)).To(Succeed())
+				})
+
+				it("prunes the reused build modules", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: packit.BuildpackInfo{
+							Name:    "Some Buildpack",
+							Version: "1.2.3",
+						},
+						WorkingDir: workingDir,
+						CNBPath:    cnbDir,
+						Layers:     packit.Layers{Path: layersDir},
+						Plan: packit.BuildpackPlan{
+							Entries: []packit.BuildpackPlanEntry{
+								{Name: "node_modules"},
+							},
+						},
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+					Expect(buffer.String()).To(ContainSubstring("Pruning devDependencies from the build modules"))
+				})
+			})
+
+			context("and were installed for another node ABI", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), []byte("[metadata]\ncache_sha = \"some-awesome-shasum\"\nnode_abi = \"108\"\n"), 0600)).To(Succeed())
+				})
+
+				it("runs the launch install", func() {
+					_, err := build(packit.BuildContext{
+						BuildpackInfo: packit.BuildpackInfo{
+							Name:    "Some Buildpack",
+							Version: "1.2.3",
+						},
+						WorkingDir: workingDir,
+						CNBPath:    cnbDir,
+						Layers:     packit.Layers{Path: layersDir},
+						Plan: packit.BuildpackPlan{
+							Entries: []packit.BuildpackPlanEntry{
+								{Name: "node_modules"},
+							},
+						},
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+					Expect(installProcess.ExecuteCall.Receives.Launch).To(BeTrue())
+					Expect(buffer.String()).NotTo(ContainSubstring("Pruning devDependencies"))
+				})
+			})
+		})
+	})
+
+	context("when incremental installs are enabled", func() {
+		var executeLayers [][]string
+
+		it.Before(func() {
+			t.Setenv("BP_YARN_INCREMENTAL_INSTALL", "true")
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			projectDir := filepath.Join(workingDir, "some-project-dir")
+			Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{"dependencies": {"leftpad": "~0.0.1"}}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("# yarn lockfile v1\n\nleftpad@~0.0.1:\n  version \"0.0.2\"\n"), 0600)).To(Succeed())
+
+			previous := filepath.Join(layersDir, "build-modules", "node_modules", "leftpad")
+			Expect(os.MkdirAll(previous, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(previous, "package.json"), []byte(`{"version": "0.0.1"}`), 0600)).To(Succeed())
+
+			executeLayers = nil
+			installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _ string, _ bool) error {
+				entries, err := os.ReadDir(filepath.Join(modulesLayerPath, "node_modules"))
+				if err != nil {
+					return err
+				}
+
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				executeLayers = append(executeLayers, names)
+
+				return os.WriteFile(filepath.Join(modulesLayerPath, "node_modules", "leftpad", "package.json"), []byte(`{"version": "0.0.2"}`), 0600)
+			}
+		})
+
+		it("restores the previous node_modules before running the install", func() {
+			_, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "1.2.3",
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+			Expect(executeLayers).To(Equal([][]string{{"leftpad"}}))
+			Expect(buffer.String()).To(ContainSubstring("Restoring node_modules from the previous build for an incremental install"))
+
+			entries, err := os.ReadDir(layersDir)
+			Expect(err).NotTo(HaveOccurred())
+			for _, entry := range entries {
+				Expect(entry.Name()).NotTo(HavePrefix(".build-modules-previous-"))
+			}
+		})
+
+		context("when the modules are only required during launch", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Build = false
+				entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+				previous := filepath.Join(layersDir, "launch-modules", "node_modules", "leftpad")
+				Expect(os.MkdirAll(previous, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(previous, "package.json"), []byte(`{"version": "0.0.1"}`), 0600)).To(Succeed())
+
+				installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _ string, _ bool) error {
+					return os.MkdirAll(filepath.Join(modulesLayerPath, "node_modules"), os.ModePerm)
+				}
+			})
+
+			it("runs a clean install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "1.2.3",
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+				Expect(filepath.Join(layersDir, "launch-modules", "node_modules", "leftpad")).NotTo(BeAnExistingFile())
+				Expect(buffer.String()).NotTo(ContainSubstring("incremental install"))
+			})
+		})
+
+		context("when the reconciled node_modules does not match yarn.lock", func() {
+			it.Before(func() {
+				installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _ string, _ bool) error {
+					entries, err := os.ReadDir(filepath.Join(modulesLayerPath, "node_modules"))
+					if err != nil {
+						return err
+					}
+
+					var names []string
+					for _, entry := range entries {
+						names = append(names, entry.Name())
+					}
+					executeLayers = append(executeLayers, names)
+
+					return nil
+				}
+			})
+
+			it("falls back to a clean install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "1.2.3",
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(2))
+				Expect(executeLayers).To(Equal([][]string{{"leftpad"}, nil}))
+				Expect(buffer.String()).To(ContainSubstring("Incremental install failed (dependency leftpad is installed at version 0.0.1 but yarn.lock resolves it to 0.0.2), falling back to a clean install"))
+			})
+		})
+
+		context("when the install cannot reconcile the previous node_modules", func() {
+			it.Before(func() {
+				installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _ string, _ bool) error {
+					if installProcess.ExecuteCall.CallCount == 1 {
+						return errors.New("failed to reconcile")
+					}
+
+					err := os.MkdirAll(filepath.Join(modulesLayerPath, "node_modules", "leftpad"), os.ModePerm)
+					if err != nil {
+						return err
+					}
+
+					return os.WriteFile(filepath.Join(modulesLayerPath, "node_modules", "leftpad", "package.json"), []byte(`{"version": "0.0.2"}`), 0600)
+				}
+			})
+
+			it("falls back to a clean install", func() {
+				_, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "1.2.3",
+					},
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(2))
+				Expect(buffer.String()).To(ContainSubstring("Incremental install failed (failed to reconcile), falling back to a clean install"))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+
+		context("when the project path parser provided fails", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_PROJECT_PATH", "does_not_exist")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					Layers:     packit.Layers{Path: layersDir},
+					CNBPath:    cnbDir,
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("could not find project path \"%s/does_not_exist\": stat %s/does_not_exist: no such file or directory", workingDir, workingDir))))
+			})
+		})
+
+		context("when determining the path for the npmrc fails", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+					if typ == "npmrc" {
+						return "", nil, errors.New("failed to determine path for npmrc")
+					}
+					return "", nil, nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError("failed to determine path for npmrc"))
+			})
+		})
+
+		context("when determining the path for the yarnrc fails", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+					if typ == "yarnrc" {
+						return "", nil, errors.New("failed to determine path for yarnrc")
+					}
+					return "", nil, nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError("failed to determine path for yarnrc"))
+			})
+		})
+
+		context("when the yarnrc-yml service binding cannot be parsed", func() {
+			var bindingDir string
+
+			it.Before(func() {
+				var err error
+				bindingDir, err = os.MkdirTemp("", "binding")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(filepath.Join(bindingDir, ".yarnrc.yml"), []byte("%%%"), 0600)).To(Succeed())
+
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+					if typ == "yarnrc-yml" {
+						return filepath.Join(bindingDir, entry), nil, nil
+					}
+					return "", nil, nil
+				}
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(bindingDir)).To(Succeed())
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse .yarnrc.yml binding")))
+			})
+		})
+
+		context("when .npmrc service binding symlink cannot be created", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+					if typ == "npmrc" {
+						return "some-path/.npmrc", nil, nil
+					}
+					return "", nil, nil
+				}
+
+				symlinker.LinkCall.Stub = func(o string, n string) error {
+					if strings.Contains(o, ".npmrc") {
+						return errors.New("symlinking .npmrc error")
+					}
+					return nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("symlinking .npmrc error")))
+			})
+		})
+
+		context("when .yarnrc service binding symlink cannot be created", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, func() error, error) {
+					if typ == "yarnrc" {
+						return "some-path/.yarnrc", nil, nil
+					}
+					return "", nil, nil
+				}
+
+				symlinker.LinkCall.Stub = func(o string, n string) error {
+					if strings.Contains(o, ".yarnrc") {
+						return errors.New("symlinking .yarnrc error")
+					}
+					return nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("symlinking .yarnrc error")))
+			})
+		})
+
+		context("when the cache key cannot be determined", func() {
+			it.Before(func() {
+				installProcess.CacheKeyCall.Stub = nil
+				installProcess.CacheKeyCall.Returns.Error = errors.New("failed to determine cache key")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError("failed to determine cache key"))
+			})
+		})
+
+		context("during the build installation process", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Build = true
+			})
+			context("when the layer cannot be retrieved", func() {
+				it.Before(func() {
 					Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), nil, 0000)).To(Succeed())
 				})
 
@@ -958,6 +2127,20 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				})
 			})
 
+			context("when BP_YARN_INCREMENTAL_INSTALL is set incorrectly", func() {
+				it.Before(func() {
+					t.Setenv("BP_YARN_INCREMENTAL_INSTALL", "not-a-bool")
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
+						WorkingDir: workingDir,
+						Layers:     packit.Layers{Path: layersDir},
+					})
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_YARN_INCREMENTAL_INSTALL")))
+				})
+			})
+
 			context("when BP_DISABLE_SBOM is set incorrectly", func() {
 				it.Before(func() {
 					os.Setenv("BP_DISABLE_SBOM", "not-a-bool")
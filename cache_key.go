@@ -0,0 +1,119 @@
+package yarninstall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// muslLoaderPattern matches the musl dynamic loader.
+const muslLoaderPattern = "/lib/ld-musl-*.so.1"
+
+// CacheKeyInputs returns the inputs, besides yarn.lock and package.json,
+// that decide whether node_modules can be reused.
+func CacheKeyInputs(workingDir, homeDir, yarnVersion string) (map[string]string, error) {
+	inputs := map[string]string{
+		"arch": runtime.GOARCH,
+		"libc": detectLibc(),
+	}
+
+	if yarnVersion != "" {
+		inputs["yarn-version"] = yarnVersion
+	}
+
+	nodeVersion, err := NodeVersion()
+	if err != nil {
+		return nil, err
+	}
+	if nodeVersion != "" {
+		inputs["node-version"] = nodeVersion
+	}
+
+	nodeABI, err := NodeABI()
+	if err != nil {
+		return nil, err
+	}
+	if nodeABI != "" {
+		inputs["node-abi"] = nodeABI
+	}
+
+	manifest, err := ParsePackageManifest(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces, err := manifest.WorkspaceDirs(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, workspace := range workspaces {
+		sum, err := fileSHA256(filepath.Join(workingDir, workspace, "package.json"))
+		if err != nil {
+			return nil, err
+		}
+		inputs[filepath.ToSlash(filepath.Join(workspace, "package.json"))] = sum
+	}
+
+	for _, name := range []string{".npmrc", ".yarnrc", YarnrcYml} {
+		sum, err := fileSHA256(filepath.Join(homeDir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		inputs["~/"+name] = sum
+	}
+
+	return inputs, nil
+}
+
+// writeCacheKeyInputs writes the inputs as sorted key=value lines.
+func writeCacheKeyInputs(w io.Writer, inputs map[string]string) error {
+	var keys []string
+	for key := range inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		_, err := fmt.Fprintf(w, "%s=%s\n", key, inputs[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func detectLibc() string {
+	matches, _ := filepath.Glob(muslLoaderPattern)
+	if len(matches) > 0 {
+		return "musl"
+	}
+
+	return "glibc"
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCacheKey(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		homeDir    string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		homeDir, err = os.MkdirTemp("", "home")
+		Expect(err).NotTo(HaveOccurred())
+
+		t.Setenv("NODE_HOME", "")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+		Expect(os.RemoveAll(homeDir)).To(Succeed())
+	})
+
+	context("CacheKeyInputs", func() {
+		it("returns the platform and yarn version", func() {
+			inputs, err := yarninstall.CacheKeyInputs(workingDir, homeDir, "4.0.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inputs).To(HaveKeyWithValue("arch", runtime.GOARCH))
+			Expect(inputs).To(HaveKeyWithValue("yarn-version", "4.0.2"))
+			Expect(inputs).To(HaveKey("libc"))
+			Expect(inputs).NotTo(HaveKey("node-version"))
+			Expect(inputs).NotTo(HaveKey("node-abi"))
+		})
+
+		context("when the home directory holds registry configuration", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(homeDir, ".yarnrc.yml"), []byte("npmAuthToken: some-secret-token\n"), 0600)).To(Succeed())
+			})
+
+			it("records the checksum of the configuration", func() {
+				inputs, err := yarninstall.CacheKeyInputs(workingDir, homeDir, "4.0.2")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inputs).To(HaveKeyWithValue("~/.yarnrc.yml", MatchRegexp(`^sha256:[0-9a-f]{64}$`)))
+				Expect(inputs).NotTo(HaveKey("~/.npmrc"))
+
+				before := inputs["~/.yarnrc.yml"]
+
+				Expect(os.WriteFile(filepath.Join(homeDir, ".yarnrc.yml"), []byte("npmAuthToken: some-other-token\n"), 0600)).To(Succeed())
+				inputs, err = yarninstall.CacheKeyInputs(workingDir, homeDir, "4.0.2")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inputs["~/.yarnrc.yml"]).NotTo(Equal(before))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the package.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.CacheKeyInputs(workingDir, homeDir, "4.0.2")
+					Expect(err).To(MatchError(ContainSubstring("unable to decode package.json")))
+				})
+			})
+		})
+	})
+}
@@ -8,12 +8,14 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
 	"gopkg.in/yaml.v3"
 )
 
 // YarnrcConfig represents the configuration from .yarnrc.yml
 type YarnrcConfig struct {
-	NodeLinker string `yaml:"nodeLinker"`
+	NodeLinker  string `yaml:"nodeLinker"`
+	CacheFolder string `yaml:"cacheFolder"`
 }
 
 func Run(executablePath, appDir string) error {
@@ -23,18 +25,60 @@ func Run(executablePath, appDir string) error {
 		return fmt.Errorf("failed to check for Yarn Berry PnP: %w", err)
 	}
 
-	// Skip symlink setup for PnP projects
-	if isPnP {
-		return nil
-	}
-
 	fname := strings.Split(executablePath, "/")
 	layerPath := filepath.Join(fname[:len(fname)-2]...)
 	if filepath.IsAbs(executablePath) {
 		layerPath = fmt.Sprintf("/%s", layerPath)
 	}
 
-	linkPath, err := os.Readlink(filepath.Join(appDir, "node_modules"))
+	// PnP projects have no node_modules, only a package cache that may live
+	// in the layer
+	if isPnP {
+		return relinkPnPCache(appDir, layerPath)
+	}
+
+	err = relink(filepath.Join(appDir, "node_modules"), filepath.Join(layerPath, "node_modules"))
+	if err != nil {
+		return err
+	}
+
+	// The node_modules of Yarn Berry workspaces are kept in the layer under
+	// workspaces/<workspace>/node_modules
+	workspacesDir := filepath.Join(layerPath, "workspaces")
+	_, err = os.Stat(workspacesDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	return filepath.WalkDir(workspacesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || entry.Name() != "node_modules" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(workspacesDir, path)
+		if err != nil {
+			return err
+		}
+
+		err = relink(filepath.Join(appDir, rel), path)
+		if err != nil {
+			return err
+		}
+
+		return filepath.SkipDir
+	})
+}
+
+// relink points the node_modules symlink at the layer.
+func relink(appNodeModules, layerNodeModules string) error {
+	linkPath, err := os.Readlink(appNodeModules)
 	if err != nil {
 		return err
 	}
@@ -53,11 +97,58 @@ func Run(executablePath, appDir string) error {
 		return nil
 	}
 
-	return createSymlink(filepath.Join(layerPath, "node_modules"), linkPath)
+	return createSymlink(layerNodeModules, linkPath)
+}
+
+// relinkPnPCache points the PnP cache symlink at the layer.
+func relinkPnPCache(appDir, layerPath string) error {
+	layerCache := filepath.Join(layerPath, "cache")
+	_, err := os.Stat(layerCache)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	cacheFolder := ".yarn/cache"
+	content, err := os.ReadFile(filepath.Join(appDir, ".yarnrc.yml"))
+	if err == nil {
+		var config YarnrcConfig
+		if yaml.Unmarshal(content, &config) == nil && config.CacheFolder != "" {
+			cacheFolder = config.CacheFolder
+		}
+	}
+
+	appCache := filepath.Join(appDir, filepath.FromSlash(cacheFolder))
+	info, err := os.Lstat(appCache)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	return relink(appCache, layerCache)
 }
 
 // isYarnBerryPnP checks if this is a Yarn Berry project using PnP
 func isYarnBerryPnP(appDir string) (bool, error) {
+	// A Classic lockfile outweighs a stray .yarnrc.yml
+	yarnLock, err := os.Open(filepath.Join(appDir, "yarn.lock"))
+	if err == nil {
+		format := lockfile.DetectFormat(yarnLock)
+		yarnLock.Close()
+
+		if format == lockfile.FormatClassic {
+			return false, nil
+		}
+	}
+
 	// Check for .yarnrc.yml file (Berry indicator)
 	yarnrcPath := filepath.Join(appDir, ".yarnrc.yml")
 	if _, err := os.Stat(yarnrcPath); err == nil {
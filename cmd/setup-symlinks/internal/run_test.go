@@ -97,6 +97,96 @@ func testRun(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when a Classic project carries a stray .yarnrc.yml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(appDir, ".yarnrc.yml"), []byte("enableTelemetry: false\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(appDir, "yarn.lock"), []byte("# yarn lockfile v1\n"), 0600)).To(Succeed())
+		})
+
+		it("still creates the node_modules symlink", func() {
+			err := internal.Run(executablePath, appDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			link, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(filepath.Join(layerDir, "node_modules")))
+		})
+	})
+
+	context("when a Yarn Berry project uses Plug'n'Play", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(appDir, ".yarnrc.yml"), []byte("nodeLinker: pnp\ncacheFolder: ./custom-cache\n"), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(layerDir, "cache"), os.ModePerm)).To(Succeed())
+			Expect(os.Symlink(filepath.Join(tmpDir, "yarn-cache"), filepath.Join(appDir, "custom-cache"))).To(Succeed())
+		})
+
+		it("points the package cache at the layer and leaves node_modules alone", func() {
+			err := internal.Run(executablePath, appDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			link, err := os.Readlink(filepath.Join(tmpDir, "yarn-cache"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(filepath.Join(layerDir, "cache")))
+
+			Expect(filepath.Join(tmpDir, "node_modules")).NotTo(BeAnExistingFile())
+		})
+
+		context("when the project commits its cache", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(appDir, "custom-cache"))).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(appDir, "custom-cache"), os.ModePerm)).To(Succeed())
+			})
+
+			it("leaves the cache alone", func() {
+				err := internal.Run(executablePath, appDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(appDir, "custom-cache")).To(BeADirectory())
+				Expect(filepath.Join(tmpDir, "yarn-cache")).NotTo(BeAnExistingFile())
+			})
+		})
+	})
+
+	context("when a Yarn Berry project uses the pnpm linker", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(appDir, ".yarnrc.yml"), []byte("nodeLinker: pnpm\n"), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(layerDir, "node_modules", ".store", "leftpad-npm-0.0.1-abc", "package"), os.ModePerm)).To(Succeed())
+			Expect(os.Symlink(".store/leftpad-npm-0.0.1-abc/package", filepath.Join(layerDir, "node_modules", "leftpad"))).To(Succeed())
+		})
+
+		it("links the node_modules dir in the layer with its store intact", func() {
+			err := internal.Run(executablePath, appDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			link, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(filepath.Join(layerDir, "node_modules")))
+
+			Expect(filepath.Join(appDir, "node_modules", "leftpad")).To(BeADirectory())
+		})
+	})
+
+	context("when the layer holds the node_modules of Yarn Berry workspaces", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(layerDir, "workspaces", "packages", "a", "node_modules"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(appDir, "packages", "a"), os.ModePerm)).To(Succeed())
+			Expect(os.Symlink(filepath.Join(tmpDir, "workspaces", "packages", "a", "node_modules"), filepath.Join(appDir, "packages", "a", "node_modules"))).To(Succeed())
+		})
+
+		it("creates a symlink to each workspace node_modules dir in the layer", func() {
+			err := internal.Run(executablePath, appDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			link, err := os.Readlink(filepath.Join(tmpDir, "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(filepath.Join(layerDir, "node_modules")))
+
+			link, err = os.Readlink(filepath.Join(tmpDir, "workspaces", "packages", "a", "node_modules"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(filepath.Join(layerDir, "workspaces", "packages", "a", "node_modules")))
+		})
+	})
+
 	context("failure cases", func() {
 		context("when the tmp dir node_modules cannot be removed", func() {
 			it.Before(func() {
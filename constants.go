@@ -16,8 +16,17 @@ const (
 	YarnLock  = "yarn.lock"
 	PnpCjs    = ".pnp.cjs"
 
+	// Plug'n'Play artifacts written by yarn install next to .pnp.cjs
+	PnpLoaderMjs     = ".pnp.loader.mjs"
+	PnpDataJson      = ".pnp.data.json"
+	YarnUnplugged    = ".yarn/unplugged"
+	YarnInstallState = ".yarn/install-state.gz"
+
 	// Node linker options
 	NodeLinkerPnP         = "pnp"
 	NodeLinkerNodeModules = "node-modules"
 	NodeLinkerPnpm        = "pnpm"
+
+	// Plugin that provides 'yarn workspaces focus' on Yarn Berry
+	WorkspaceToolsPlugin = "@yarnpkg/plugin-workspace-tools"
 )
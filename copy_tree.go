@@ -0,0 +1,134 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// CopyStrategyReflink clones files so that they share their data blocks
+	// until either copy is written to.
+	CopyStrategyReflink = "reflink"
+
+	// CopyStrategyHardlink links files so that both trees share the same
+	// inodes.
+	CopyStrategyHardlink = "hardlink"
+
+	// CopyStrategyCopy copies the content of files.
+	CopyStrategyCopy = "copy"
+)
+
+// CopyTree copies source to destination and returns the strategy it used.
+func CopyTree(source, destination string, allowHardlinks bool) (string, error) {
+	strategy := CopyStrategyReflink
+
+	err := filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(link, target)
+
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+
+		case info.Mode().IsRegular():
+			strategy, err = copyFile(path, target, info.Mode().Perm(), strategy, allowHardlinks)
+			return err
+
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", source, err)
+	}
+
+	return strategy, nil
+}
+
+// copyFile copies a file, falling back to the next strategy.
+func copyFile(source, destination string, mode os.FileMode, strategy string, allowHardlinks bool) (string, error) {
+	if strategy == CopyStrategyReflink {
+		err := reflinkFile(source, destination, mode)
+		if err == nil {
+			return strategy, nil
+		}
+
+		strategy = CopyStrategyCopy
+		if allowHardlinks {
+			strategy = CopyStrategyHardlink
+		}
+	}
+
+	if strategy == CopyStrategyHardlink {
+		err := os.Link(source, destination)
+		if err == nil {
+			return strategy, nil
+		}
+
+		strategy = CopyStrategyCopy
+	}
+
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return strategy, err
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return strategy, err
+	}
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	if err != nil {
+		return strategy, err
+	}
+
+	return strategy, nil
+}
+
+func reflinkFile(source, destination string, mode os.FileMode) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+
+	err = reflink(sourceFile, destinationFile)
+	closeErr := destinationFile.Close()
+	if err != nil {
+		return errors.Join(err, os.Remove(destination))
+	}
+
+	return closeErr
+}
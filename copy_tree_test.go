@@ -0,0 +1,94 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCopyTree(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		source      string
+		destination string
+	)
+
+	it.Before(func() {
+		var err error
+		source, err = os.MkdirTemp("", "source")
+		Expect(err).NotTo(HaveOccurred())
+
+		destination, err = os.MkdirTemp("", "destination")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(source, "leftpad", "bin"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(source, "leftpad", "package.json"), []byte(`{"version": "0.0.1"}`), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(source, "leftpad", "bin", "leftpad"), []byte("#!/usr/bin/env node"), 0755)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(source, ".bin"), os.ModePerm)).To(Succeed())
+		Expect(os.Symlink("../leftpad/bin/leftpad", filepath.Join(source, ".bin", "leftpad"))).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(source)).To(Succeed())
+		Expect(os.RemoveAll(destination)).To(Succeed())
+	})
+
+	it("copies the tree and keeps symlinks and modes", func() {
+		strategy, err := yarninstall.CopyTree(source, filepath.Join(destination, "node_modules"), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strategy).To(BeElementOf(yarninstall.CopyStrategyReflink, yarninstall.CopyStrategyCopy))
+
+		content, err := os.ReadFile(filepath.Join(destination, "node_modules", "leftpad", "package.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal(`{"version": "0.0.1"}`))
+
+		info, err := os.Stat(filepath.Join(destination, "node_modules", "leftpad", "bin", "leftpad"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+		link, err := os.Readlink(filepath.Join(destination, "node_modules", ".bin", "leftpad"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal("../leftpad/bin/leftpad"))
+
+		sourceInfo, err := os.Stat(filepath.Join(source, "leftpad", "package.json"))
+		Expect(err).NotTo(HaveOccurred())
+		destinationInfo, err := os.Stat(filepath.Join(destination, "node_modules", "leftpad", "package.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.SameFile(sourceInfo, destinationInfo)).To(BeFalse())
+	})
+
+	context("when hardlinks are allowed", func() {
+		it("shares the files that cannot be cloned", func() {
+			strategy, err := yarninstall.CopyTree(source, filepath.Join(destination, "node_modules"), true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strategy).To(BeElementOf(yarninstall.CopyStrategyReflink, yarninstall.CopyStrategyHardlink, yarninstall.CopyStrategyCopy))
+
+			sourceInfo, err := os.Stat(filepath.Join(source, "leftpad", "package.json"))
+			Expect(err).NotTo(HaveOccurred())
+			destinationInfo, err := os.Stat(filepath.Join(destination, "node_modules", "leftpad", "package.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.SameFile(sourceInfo, destinationInfo)).To(Equal(strategy == yarninstall.CopyStrategyHardlink))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the destination already exists", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(destination, "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(destination, "node_modules", "leftpad", "package.json"), []byte("{}"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := yarninstall.CopyTree(source, filepath.Join(destination, "node_modules"), false)
+				Expect(err).To(MatchError(ContainSubstring("failed to copy")))
+				Expect(err).To(MatchError(os.ErrExist))
+			})
+		})
+	})
+}
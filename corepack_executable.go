@@ -0,0 +1,31 @@
+package yarninstall
+
+import (
+	"os"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// CorepackExecutable runs the Yarn release pinned by packageManager.
+type CorepackExecutable struct {
+	corepack Executable
+}
+
+func NewCorepackExecutable(corepack Executable) CorepackExecutable {
+	return CorepackExecutable{
+		corepack: corepack,
+	}
+}
+
+func (e CorepackExecutable) Execute(execution pexec.Execution) error {
+	execution.Args = append([]string{"yarn"}, execution.Args...)
+
+	// The build cannot answer the prompt that corepack shows before it
+	// downloads a release
+	if execution.Env == nil {
+		execution.Env = os.Environ()
+	}
+	execution.Env = append(execution.Env, "COREPACK_ENABLE_DOWNLOAD_PROMPT=0")
+
+	return e.corepack.Execute(execution)
+}
@@ -0,0 +1,67 @@
+package yarninstall_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/paketo-buildpacks/yarn-install/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCorepackExecutable(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		corepack   *fakes.Executable
+		executable yarninstall.CorepackExecutable
+	)
+
+	it.Before(func() {
+		corepack = &fakes.Executable{}
+		executable = yarninstall.NewCorepackExecutable(corepack)
+	})
+
+	it("runs yarn through corepack without prompting", func() {
+		buffer := bytes.NewBuffer(nil)
+		err := executable.Execute(pexec.Execution{
+			Args:   []string{"install", "--immutable"},
+			Dir:    "/some/project",
+			Env:    []string{"SOME_VAR=some-value"},
+			Stdout: buffer,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(corepack.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"yarn", "install", "--immutable"}))
+		Expect(corepack.ExecuteCall.Receives.Execution.Dir).To(Equal("/some/project"))
+		Expect(corepack.ExecuteCall.Receives.Execution.Env).To(Equal([]string{"SOME_VAR=some-value", "COREPACK_ENABLE_DOWNLOAD_PROMPT=0"}))
+		Expect(corepack.ExecuteCall.Receives.Execution.Stdout).To(Equal(buffer))
+	})
+
+	context("when the execution has no environment", func() {
+		it.Before(func() {
+			t.Setenv("SOME_VAR", "some-value")
+		})
+
+		it("keeps the environment of the build", func() {
+			Expect(executable.Execute(pexec.Execution{Args: []string{"--version"}})).To(Succeed())
+
+			Expect(corepack.ExecuteCall.Receives.Execution.Env).To(ContainElements("SOME_VAR=some-value", "COREPACK_ENABLE_DOWNLOAD_PROMPT=0"))
+		})
+	})
+
+	context("when corepack fails", func() {
+		it.Before(func() {
+			corepack.ExecuteCall.Returns.Error = errors.New("some-error")
+		})
+
+		it("returns the error", func() {
+			err := executable.Execute(pexec.Execution{Args: []string{"install"}})
+			Expect(err).To(MatchError("some-error"))
+		})
+	})
+}
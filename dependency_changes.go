@@ -0,0 +1,151 @@
+package yarninstall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// DependencyChangesFile is the report written into a modules layer.
+const DependencyChangesFile = "dependency-changes.json"
+
+// lockfileSnapshotKey is the metadata key of the lockfile snapshot digest.
+const lockfileSnapshotKey = "lockfile_sha"
+
+// SaveLockfileSnapshot writes the package versions of a yarn.lock to path.
+func SaveLockfileSnapshot(lf lockfile.Lockfile, path string, metadata map[string]interface{}) error {
+	snapshot := map[string][]string{}
+	for _, pkg := range lf.Packages {
+		if strings.Contains(pkg.Resolved, "@workspace:") {
+			continue
+		}
+
+		versions := snapshot[pkg.Name]
+		if !slices.Contains(versions, pkg.Version) {
+			versions = append(versions, pkg.Version)
+			sort.Strings(versions)
+		}
+		snapshot[pkg.Name] = versions
+	}
+
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode yarn.lock snapshot: %w", err)
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write yarn.lock snapshot: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	metadata[lockfileSnapshotKey] = "sha256:" + hex.EncodeToString(sum[:])
+
+	return nil
+}
+
+// ReadLockfileSnapshot reads the snapshot saved by SaveLockfileSnapshot.
+func ReadLockfileSnapshot(path string, metadata map[string]interface{}) (lockfile.Lockfile, bool) {
+	digest, ok := metadata[lockfileSnapshotKey].(string)
+	if !ok {
+		return lockfile.Lockfile{}, false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return lockfile.Lockfile{}, false
+	}
+
+	sum := sha256.Sum256(content)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return lockfile.Lockfile{}, false
+	}
+
+	var snapshot map[string][]string
+	err = json.Unmarshal(content, &snapshot)
+	if err != nil {
+		return lockfile.Lockfile{}, false
+	}
+
+	var names []string
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lf lockfile.Lockfile
+	for _, name := range names {
+		for _, version := range snapshot[name] {
+			lf.Packages = append(lf.Packages, lockfile.Package{Name: name, Version: version})
+		}
+	}
+
+	return lf, true
+}
+
+// ReportDependencyChanges writes the changes between two lockfiles into
+// the layer.
+func ReportDependencyChanges(previous, current lockfile.Lockfile, layerPath string) (lockfile.Changes, error) {
+	changes := lockfile.Diff(previous, current)
+
+	// Consumers of the report can rely on every list being present
+	report := changes
+	for _, list := range []*[]lockfile.Change{&report.Added, &report.Removed, &report.Upgraded, &report.Downgraded} {
+		if *list == nil {
+			*list = []lockfile.Change{}
+		}
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return lockfile.Changes{}, fmt.Errorf("failed to encode dependency changes: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(layerPath, DependencyChangesFile), append(content, '\n'), 0644)
+	if err != nil {
+		return lockfile.Changes{}, fmt.Errorf("failed to write dependency changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+func logDependencyChanges(logger scribe.Emitter, changes lockfile.Changes) {
+	if changes.Empty() {
+		logger.Subprocess("No dependency changes since the cached layer")
+		logger.Break()
+		return
+	}
+
+	logger.Subprocess("Dependency changes since the cached layer:")
+
+	for _, section := range []struct {
+		title   string
+		changes []lockfile.Change
+		format  func(lockfile.Change) string
+	}{
+		{"Added", changes.Added, func(c lockfile.Change) string { return fmt.Sprintf("%s@%s", c.Name, c.To) }},
+		{"Removed", changes.Removed, func(c lockfile.Change) string { return fmt.Sprintf("%s@%s", c.Name, c.From) }},
+		{"Upgraded", changes.Upgraded, func(c lockfile.Change) string { return fmt.Sprintf("%s %s → %s", c.Name, c.From, c.To) }},
+		{"Downgraded", changes.Downgraded, func(c lockfile.Change) string { return fmt.Sprintf("%s %s → %s", c.Name, c.From, c.To) }},
+	} {
+		if len(section.changes) == 0 {
+			continue
+		}
+
+		logger.Action("%s (%d)", section.title, len(section.changes))
+		for _, change := range section.changes {
+			logger.Detail(section.format(change))
+		}
+	}
+
+	logger.Break()
+}
@@ -0,0 +1,238 @@
+package yarninstall_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDependencyChanges(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectDir   string
+		layerDir     string
+		snapshotPath string
+		metadata     map[string]interface{}
+	)
+
+	it.Before(func() {
+		var err error
+		projectDir, err = os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+
+		layerDir, err = os.MkdirTemp("", "layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		snapshotPath = filepath.Join(layerDir, "snapshot.json")
+		Expect(os.WriteFile(snapshotPath, []byte(`{"debug":["2.6.9"],"express":["4.17.3"],"leftpad":["0.0.2"]}`), 0600)).To(Succeed())
+
+		metadata = map[string]interface{}{
+			"cache_sha":    "some-sha",
+			"lockfile_sha": "sha256:83785aa7e16fb300f8afa316bf8e4bfb14e7a80196a1d9919058503693df8f5b",
+		}
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+express@^4.18.0:
+  version "4.18.2"
+
+leftpad@0.0.1:
+  version "0.0.1"
+
+lodash@^4.17.21:
+  version "4.17.21"
+`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(projectDir)).To(Succeed())
+		Expect(os.RemoveAll(layerDir)).To(Succeed())
+	})
+
+	context("SaveLockfileSnapshot", func() {
+		it("writes the package versions of the lockfile and records its digest", func() {
+			lf, err := lockfile.Parse(filepath.Join(projectDir, "yarn.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			metadata := map[string]interface{}{"cache_sha": "some-sha"}
+			Expect(yarninstall.SaveLockfileSnapshot(lf, snapshotPath, metadata)).To(Succeed())
+
+			content, err := os.ReadFile(snapshotPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{"express": ["4.18.2"], "leftpad": ["0.0.1"], "lodash": ["4.17.21"]}`))
+
+			Expect(metadata).To(HaveLen(2))
+			Expect(metadata).To(HaveKeyWithValue("lockfile_sha", MatchRegexp(`^sha256:[0-9a-f]{64}$`)))
+		})
+
+		context("failure cases", func() {
+			context("when the snapshot cannot be written", func() {
+				it.Before(func() {
+					Expect(os.Chmod(layerDir, 0500)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Chmod(layerDir, os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := yarninstall.SaveLockfileSnapshot(lockfile.Lockfile{}, filepath.Join(layerDir, "other.json"), map[string]interface{}{})
+					Expect(err).To(MatchError(ContainSubstring("failed to write yarn.lock snapshot")))
+				})
+			})
+		})
+	})
+
+	context("ReadLockfileSnapshot", func() {
+		it("returns the packages of the snapshot", func() {
+			lf, ok := yarninstall.ReadLockfileSnapshot(snapshotPath, metadata)
+			Expect(ok).To(BeTrue())
+			Expect(lf.Packages).To(Equal([]lockfile.Package{
+				{Name: "debug", Version: "2.6.9"},
+				{Name: "express", Version: "4.17.3"},
+				{Name: "leftpad", Version: "0.0.2"},
+			}))
+		})
+
+		context("when the snapshot was saved during this build", func() {
+			it.Before(func() {
+				lf, err := lockfile.Parse(filepath.Join(projectDir, "yarn.lock"))
+				Expect(err).NotTo(HaveOccurred())
+
+				metadata = map[string]interface{}{}
+				Expect(yarninstall.SaveLockfileSnapshot(lf, snapshotPath, metadata)).To(Succeed())
+			})
+
+			it("returns the packages of the snapshot", func() {
+				lf, ok := yarninstall.ReadLockfileSnapshot(snapshotPath, metadata)
+				Expect(ok).To(BeTrue())
+				Expect(lf.Packages).To(Equal([]lockfile.Package{
+					{Name: "express", Version: "4.18.2"},
+					{Name: "leftpad", Version: "0.0.1"},
+					{Name: "lodash", Version: "4.17.21"},
+				}))
+			})
+		})
+
+		context("when the layer recorded no snapshot", func() {
+			it.Before(func() {
+				delete(metadata, "lockfile_sha")
+			})
+
+			it("reports that there is none", func() {
+				_, ok := yarninstall.ReadLockfileSnapshot(snapshotPath, metadata)
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		context("when the snapshot does not match the digest", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(snapshotPath, []byte(`{"leftpad":["0.0.3"]}`), 0600)).To(Succeed())
+			})
+
+			it("reports that there is none", func() {
+				_, ok := yarninstall.ReadLockfileSnapshot(snapshotPath, metadata)
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	context("ReportDependencyChanges", func() {
+		var previous, current lockfile.Lockfile
+
+		it.Before(func() {
+			var ok bool
+			previous, ok = yarninstall.ReadLockfileSnapshot(snapshotPath, metadata)
+			Expect(ok).To(BeTrue())
+
+			var err error
+			current, err = lockfile.Parse(filepath.Join(projectDir, "yarn.lock"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("returns the changes and writes them into the layer", func() {
+			changes, err := yarninstall.ReportDependencyChanges(previous, current, layerDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal(lockfile.Changes{
+				Added:      []lockfile.Change{{Name: "lodash", To: "4.17.21"}},
+				Removed:    []lockfile.Change{{Name: "debug", From: "2.6.9"}},
+				Upgraded:   []lockfile.Change{{Name: "express", From: "4.17.3", To: "4.18.2"}},
+				Downgraded: []lockfile.Change{{Name: "leftpad", From: "0.0.2", To: "0.0.1"}},
+			}))
+
+			content, err := os.ReadFile(filepath.Join(layerDir, yarninstall.DependencyChangesFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"added": [{"name": "lodash", "to": "4.17.21"}],
+				"removed": [{"name": "debug", "from": "2.6.9"}],
+				"upgraded": [{"name": "express", "from": "4.17.3", "to": "4.18.2"}],
+				"downgraded": [{"name": "leftpad", "from": "0.0.2", "to": "0.0.1"}]
+			}`))
+		})
+
+		context("when nothing changed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+debug@^2.6.0:
+  version "2.6.9"
+
+express@^4.17.0:
+  version "4.17.3"
+  dependencies:
+    debug "^2.6.0"
+
+leftpad@~0.0.1:
+  version "0.0.2"
+`), 0600)).To(Succeed())
+
+				var err error
+				current, err = lockfile.Parse(filepath.Join(projectDir, "yarn.lock"))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("writes empty lists", func() {
+				changes, err := yarninstall.ReportDependencyChanges(previous, current, layerDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(changes.Empty()).To(BeTrue())
+
+				content, err := os.ReadFile(filepath.Join(layerDir, yarninstall.DependencyChangesFile))
+				Expect(err).NotTo(HaveOccurred())
+
+				var report map[string][]interface{}
+				Expect(json.Unmarshal(content, &report)).To(Succeed())
+				Expect(report).To(Equal(map[string][]interface{}{
+					"added":      {},
+					"removed":    {},
+					"upgraded":   {},
+					"downgraded": {},
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the report cannot be written", func() {
+				it.Before(func() {
+					Expect(os.Chmod(layerDir, 0500)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Chmod(layerDir, os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.ReportDependencyChanges(previous, current, layerDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to write dependency changes")))
+				})
+			})
+		})
+	})
+}
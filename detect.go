@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/paketo-buildpacks/libnodejs"
 	"github.com/paketo-buildpacks/packit/v2"
@@ -42,7 +43,10 @@ func Detect() packit.DetectFunc {
 		}
 
 		// Determine what to provide based on configuration
-		provisionType := DetermineProvisionType(projectPath, yarnrcConfig)
+		provisionType, err := DetermineProvisionType(projectPath, yarnrcConfig)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
 
 		pkg, err := libnodejs.ParsePackageJSON(projectPath)
 		if err != nil {
@@ -69,20 +73,58 @@ func Detect() packit.DetectFunc {
 			}
 		}
 
+		manifest, err := ParsePackageManifest(projectPath)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		yarnRequirement := packit.BuildPlanRequirement{
+			Name: PlanDependencyYarn,
+			Metadata: BuildPlanMetadata{
+				Build: true,
+			},
+		}
+
+		// The corepack-style packageManager field pins an exact release and
+		// therefore takes precedence over the engines.yarn range. The yarn
+		// buildpack only provides Yarn Classic, so Berry versions are left to
+		// the release that the project vendors or to corepack.
+		pinsBerry := yarnGeneration(manifest.YarnVersion()) == YarnBerry
+		if yarnVersion := manifest.YarnVersion(); yarnVersion != "" {
+			if yarnGeneration(yarnVersion) == YarnClassic {
+				yarnRequirement.Metadata = BuildPlanMetadata{
+					Version:       yarnVersion,
+					VersionSource: "packageManager",
+					Build:         true,
+				}
+			}
+		} else if yarnGeneration(strings.TrimLeft(manifest.Engines.Yarn, "^~<>=v ")) == YarnClassic {
+			yarnRequirement.Metadata = BuildPlanMetadata{
+				Version:       manifest.Engines.Yarn,
+				VersionSource: "package.json",
+				Build:         true,
+			}
+		}
+
+		requirements := []packit.BuildPlanRequirement{nodeRequirement}
+
+		// A project that vendors its Yarn release or pins a Berry release for
+		// corepack only needs node to run it
+		yarnRelease, err := FindYarnRelease(projectPath, yarnrcConfig)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if yarnRelease == "" && !pinsBerry {
+			requirements = append(requirements, yarnRequirement)
+		}
+
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
 				Provides: []packit.BuildPlanProvision{
 					{Name: provisionType},
 				},
-				Requires: []packit.BuildPlanRequirement{
-					nodeRequirement,
-					{
-						Name: PlanDependencyYarn,
-						Metadata: BuildPlanMetadata{
-							Build: true,
-						},
-					},
-				},
+				Requires: requirements,
 			},
 		}, nil
 	}
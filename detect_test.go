@@ -104,6 +104,145 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the yarn version is set in the engines field", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filePath, []byte(`{
+				"engines": {
+					"node": "some-version",
+					"yarn": "^1.22.0"
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("returns a plan that requires that yarn version", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "yarn",
+				Metadata: yarninstall.BuildPlanMetadata{
+					Version:       "^1.22.0",
+					VersionSource: "package.json",
+					Build:         true,
+				},
+			}))
+		})
+	})
+
+	context("when the yarn version is pinned by the packageManager field", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filePath, []byte(`{
+				"packageManager": "yarn@1.22.19+sha224.abc",
+				"engines": {
+					"node": "some-version",
+					"yarn": "^1.22.0"
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("returns a plan that requires the pinned yarn version", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "yarn",
+				Metadata: yarninstall.BuildPlanMetadata{
+					Version:       "1.22.19",
+					VersionSource: "packageManager",
+					Build:         true,
+				},
+			}))
+		})
+	})
+
+	context("when the packageManager field pins a Yarn Berry release", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filePath, []byte(`{
+				"packageManager": "yarn@4.0.2",
+				"engines": {
+					"node": "some-version",
+					"yarn": "^1.22.0"
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("returns a plan that leaves yarn to corepack", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: "node",
+					Metadata: yarninstall.BuildPlanMetadata{
+						Version:       "some-version",
+						VersionSource: "package.json",
+						Build:         true,
+					},
+				},
+			}))
+		})
+	})
+
+	context("when the engines field requires Yarn Berry", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filePath, []byte(`{
+				"engines": {
+					"node": "some-version",
+					"yarn": ">=3.0.0"
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("returns a plan that requires yarn without a version", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "yarn",
+				Metadata: yarninstall.BuildPlanMetadata{
+					Build: true,
+				},
+			}))
+		})
+	})
+
+	context("when the project vendors its Yarn release", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "custom", ".yarn", "releases"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "custom", ".yarn", "releases", "yarn-4.0.2.cjs"), []byte{}, 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "custom", ".yarnrc.yml"), []byte(`
+nodeLinker: node-modules
+yarnPath: .yarn/releases/yarn-4.0.2.cjs
+`), 0644)).To(Succeed())
+		})
+
+		it("returns a plan that does not require yarn", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "node_modules"},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name: "node",
+						Metadata: yarninstall.BuildPlanMetadata{
+							Version:       "some-version",
+							VersionSource: "package.json",
+							Build:         true,
+						},
+					},
+				},
+			}))
+		})
+	})
+
 	context("when there is no yarn.lock file", func() {
 		it.Before(func() {
 			Expect(os.Remove(filepath.Join(workingDir, "custom", "yarn.lock"))).To(Succeed())
@@ -12,14 +12,15 @@ type ConfigurationManager struct {
 			Entry       string
 		}
 		Returns struct {
-			Path string
-			Err  error
+			Path    string
+			Cleanup func() error
+			Err     error
 		}
-		Stub func(string, string, string) (string, error)
+		Stub func(string, string, string) (string, func() error, error)
 	}
 }
 
-func (f *ConfigurationManager) DeterminePath(param1 string, param2 string, param3 string) (string, error) {
+func (f *ConfigurationManager) DeterminePath(param1 string, param2 string, param3 string) (string, func() error, error) {
 	f.DeterminePathCall.mutex.Lock()
 	defer f.DeterminePathCall.mutex.Unlock()
 	f.DeterminePathCall.CallCount++
@@ -29,5 +30,5 @@ func (f *ConfigurationManager) DeterminePath(param1 string, param2 string, param
 	if f.DeterminePathCall.Stub != nil {
 		return f.DeterminePathCall.Stub(param1, param2, param3)
 	}
-	return f.DeterminePathCall.Returns.Path, f.DeterminePathCall.Returns.Err
+	return f.DeterminePathCall.Returns.Path, f.DeterminePathCall.Returns.Cleanup, f.DeterminePathCall.Returns.Err
 }
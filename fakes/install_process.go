@@ -3,18 +3,32 @@ package fakes
 import "sync"
 
 type InstallProcess struct {
+	CacheKeyCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir  string
+			YarnVersion string
+		}
+		Returns struct {
+			MapStringString map[string]string
+			Error           error
+		}
+		Stub func(string, string) (map[string]string, error)
+	}
 	ExecuteCall struct {
 		mutex     sync.Mutex
 		CallCount int
 		Receives  struct {
 			WorkingDir       string
 			ModulesLayerPath string
+			CacheLayerPath   string
 			Launch           bool
 		}
 		Returns struct {
 			Error error
 		}
-		Stub func(string, string, bool) error
+		Stub func(string, string, string, bool) error
 	}
 	SetupModulesCall struct {
 		mutex     sync.Mutex
@@ -34,8 +48,9 @@ type InstallProcess struct {
 		mutex     sync.Mutex
 		CallCount int
 		Receives  struct {
-			WorkingDir string
-			Metadata   map[string]interface {
+			WorkingDir  string
+			YarnVersion string
+			Metadata    map[string]interface {
 			}
 		}
 		Returns struct {
@@ -43,20 +58,32 @@ type InstallProcess struct {
 			Sha string
 			Err error
 		}
-		Stub func(string, map[string]interface {
+		Stub func(string, string, map[string]interface {
 		}) (bool, string, error)
 	}
 }
 
-func (f *InstallProcess) Execute(param1 string, param2 string, param3 bool) error {
+func (f *InstallProcess) CacheKey(param1 string, param2 string) (map[string]string, error) {
+	f.CacheKeyCall.mutex.Lock()
+	defer f.CacheKeyCall.mutex.Unlock()
+	f.CacheKeyCall.CallCount++
+	f.CacheKeyCall.Receives.WorkingDir = param1
+	f.CacheKeyCall.Receives.YarnVersion = param2
+	if f.CacheKeyCall.Stub != nil {
+		return f.CacheKeyCall.Stub(param1, param2)
+	}
+	return f.CacheKeyCall.Returns.MapStringString, f.CacheKeyCall.Returns.Error
+}
+func (f *InstallProcess) Execute(param1 string, param2 string, param3 string, param4 bool) error {
 	f.ExecuteCall.mutex.Lock()
 	defer f.ExecuteCall.mutex.Unlock()
 	f.ExecuteCall.CallCount++
 	f.ExecuteCall.Receives.WorkingDir = param1
 	f.ExecuteCall.Receives.ModulesLayerPath = param2
-	f.ExecuteCall.Receives.Launch = param3
+	f.ExecuteCall.Receives.CacheLayerPath = param3
+	f.ExecuteCall.Receives.Launch = param4
 	if f.ExecuteCall.Stub != nil {
-		return f.ExecuteCall.Stub(param1, param2, param3)
+		return f.ExecuteCall.Stub(param1, param2, param3, param4)
 	}
 	return f.ExecuteCall.Returns.Error
 }
@@ -72,15 +99,16 @@ func (f *InstallProcess) SetupModules(param1 string, param2 string, param3 strin
 	}
 	return f.SetupModulesCall.Returns.String, f.SetupModulesCall.Returns.Error
 }
-func (f *InstallProcess) ShouldRun(param1 string, param2 map[string]interface {
+func (f *InstallProcess) ShouldRun(param1 string, param2 string, param3 map[string]interface {
 }) (bool, string, error) {
 	f.ShouldRunCall.mutex.Lock()
 	defer f.ShouldRunCall.mutex.Unlock()
 	f.ShouldRunCall.CallCount++
 	f.ShouldRunCall.Receives.WorkingDir = param1
-	f.ShouldRunCall.Receives.Metadata = param2
+	f.ShouldRunCall.Receives.YarnVersion = param2
+	f.ShouldRunCall.Receives.Metadata = param3
 	if f.ShouldRunCall.Stub != nil {
-		return f.ShouldRunCall.Stub(param1, param2)
+		return f.ShouldRunCall.Stub(param1, param2, param3)
 	}
 	return f.ShouldRunCall.Returns.Run, f.ShouldRunCall.Returns.Sha, f.ShouldRunCall.Returns.Err
 }
@@ -3,7 +3,7 @@ package fakes
 import (
 	"sync"
 
-	"github.com/paketo-buildpacks/packit/v2/sbom"
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
 )
 
 type SBOMGenerator struct {
@@ -11,23 +11,25 @@ type SBOMGenerator struct {
 		mutex     sync.Mutex
 		CallCount int
 		Receives  struct {
-			Dir string
+			ProjectPath      string
+			ModulesLayerPath string
 		}
 		Returns struct {
-			SBOM  sbom.SBOM
-			Error error
+			LayerSBOM yarninstall.LayerSBOM
+			Error     error
 		}
-		Stub func(string) (sbom.SBOM, error)
+		Stub func(string, string) (yarninstall.LayerSBOM, error)
 	}
 }
 
-func (f *SBOMGenerator) Generate(param1 string) (sbom.SBOM, error) {
+func (f *SBOMGenerator) Generate(param1 string, param2 string) (yarninstall.LayerSBOM, error) {
 	f.GenerateCall.mutex.Lock()
 	defer f.GenerateCall.mutex.Unlock()
 	f.GenerateCall.CallCount++
-	f.GenerateCall.Receives.Dir = param1
+	f.GenerateCall.Receives.ProjectPath = param1
+	f.GenerateCall.Receives.ModulesLayerPath = param2
 	if f.GenerateCall.Stub != nil {
-		return f.GenerateCall.Stub(param1)
+		return f.GenerateCall.Stub(param1, param2)
 	}
-	return f.GenerateCall.Returns.SBOM, f.GenerateCall.Returns.Error
+	return f.GenerateCall.Returns.LayerSBOM, f.GenerateCall.Returns.Error
 }
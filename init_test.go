@@ -9,12 +9,26 @@ import (
 
 func TestUnitYarn(t *testing.T) {
 	suite := spec.New("yarn", spec.Report(report.Terminal{}))
+	suite("BerryInstallProcess", testBerryInstallProcess)
 	suite("Build", testBuild)
 	suite("CacheHandler", testCacheHandler)
+	suite("CacheKey", testCacheKey)
+	suite("CopyTree", testCopyTree)
+	suite("CorepackExecutable", testCorepackExecutable)
+	suite("DependencyChanges", testDependencyChanges)
 	suite("Detect", testDetect)
 	suite("InstallProcess", testInstallProcess)
+	suite("Invalidation", testInvalidation)
+	suite("LayerSBOM", testLayerSBOM)
+	suite("ModulesSBOM", testModulesSBOM)
+	suite("NodeABI", testNodeABI)
+	suite("NodeModulesIntegrity", testNodeModulesIntegrity)
+	suite("PackageJSON", testPackageJSON)
 	suite("PackageManagerConfigurationManager", testPackageManagerConfigurationManager)
+	suite("Prune", testPrune)
 	suite("Symlinker", testSymlinker)
+	suite("YarnReleaseExecutable", testYarnReleaseExecutable)
+	suite("YarnrcBinding", testYarnrcBinding)
 	suite("YarnrcParser", testYarnrcParser)
 	suite("YarnBerryIntegration", testYarnBerryIntegration)
 	suite.Run(t)
@@ -2,12 +2,16 @@ package yarninstall
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
@@ -26,18 +30,20 @@ type Executable interface {
 type YarnInstallProcess struct {
 	executable Executable
 	summer     Summer
+	homeDir    string
 	logger     scribe.Emitter
 }
 
-func NewYarnInstallProcess(executable Executable, summer Summer, logger scribe.Emitter) YarnInstallProcess {
+func NewYarnInstallProcess(executable Executable, summer Summer, homeDir string, logger scribe.Emitter) YarnInstallProcess {
 	return YarnInstallProcess{
 		executable: executable,
 		summer:     summer,
+		homeDir:    homeDir,
 		logger:     logger,
 	}
 }
 
-func (ip YarnInstallProcess) ShouldRun(workingDir string, metadata map[string]interface{}) (run bool, sha string, err error) {
+func (ip YarnInstallProcess) ShouldRun(workingDir, yarnVersion string, metadata map[string]interface{}) (run bool, sha string, err error) {
 	ip.logger.Subprocess("Process inputs:")
 
 	_, err = os.Stat(filepath.Join(workingDir, "yarn.lock"))
@@ -52,21 +58,26 @@ func (ip YarnInstallProcess) ShouldRun(workingDir string, metadata map[string]in
 	ip.logger.Action("yarn.lock -> Found")
 	ip.logger.Break()
 
-	buffer := bytes.NewBuffer(nil)
+	inputs, err := CacheKeyInputs(workingDir, ip.homeDir, yarnVersion)
+	if err != nil {
+		return true, "", fmt.Errorf("failed to determine cache key inputs: %w", err)
+	}
 
-	err = ip.executable.Execute(pexec.Execution{
-		Args:   []string{"config", "list", "--silent"},
-		Stdout: buffer,
-		Stderr: buffer,
-		Dir:    workingDir,
-	})
+	config, err := ip.yarnConfig(workingDir)
 	if err != nil {
-		return true, "", fmt.Errorf("failed to execute yarn config output:\n%s\nerror: %s", buffer.String(), err)
+		return true, "", err
 	}
 
+	buffer := bytes.NewBufferString(config)
+
 	nodeEnv := os.Getenv("NODE_ENV")
 	buffer.WriteString(nodeEnv)
 
+	err = writeCacheKeyInputs(buffer, inputs)
+	if err != nil {
+		return true, "", err
+	}
+
 	file, err := os.CreateTemp("", "config-file")
 	if err != nil {
 		return true, "", fmt.Errorf("failed to create temp file for %s: %w", file.Name(), err)
@@ -91,13 +102,56 @@ func (ip YarnInstallProcess) ShouldRun(workingDir string, metadata map[string]in
 	return false, "", nil
 }
 
+// CacheKey returns the components of the cache key that ShouldRun hashes, as
+// they are recorded in the metadata of a modules layer.
+func (ip YarnInstallProcess) CacheKey(workingDir, yarnVersion string) (map[string]string, error) {
+	config, err := ip.yarnConfig(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	components, err := CacheKeyComponents(workingDir, ip.homeDir, yarnVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Yarn Classic does not read .yarnrc.yml, the configuration it does read
+	// is part of the key through the output of 'yarn config list'
+	delete(components, YarnrcYml)
+	sum := sha256.Sum256([]byte(config))
+	components["yarn config list"] = hex.EncodeToString(sum[:])
+
+	return components, nil
+}
+
+func (ip YarnInstallProcess) yarnConfig(workingDir string) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	err := ip.executable.Execute(pexec.Execution{
+		Args:   []string{"config", "list", "--silent"},
+		Stdout: buffer,
+		Stderr: buffer,
+		Dir:    workingDir,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute yarn config output:\n%s\nerror: %s", buffer.String(), err)
+	}
+
+	return buffer.String(), nil
+}
+
 func (ip YarnInstallProcess) SetupModules(workingDir, currentModulesLayerPath, nextModulesLayerPath string) (string, error) {
 	if currentModulesLayerPath != "" {
-		err := fs.Copy(filepath.Join(currentModulesLayerPath, "node_modules"), filepath.Join(nextModulesLayerPath, "node_modules"))
+		var strategy string
+		duration, err := chronos.DefaultClock.Measure(func() error {
+			var err error
+			strategy, err = CopyTree(filepath.Join(currentModulesLayerPath, "node_modules"), filepath.Join(nextModulesLayerPath, "node_modules"), false)
+			return err
+		})
 		if err != nil {
 			return "", fmt.Errorf("failed to copy node_modules directory: %w", err)
 		}
 
+		ip.logger.Subprocess("Copied node_modules from %s (%s) in %s", currentModulesLayerPath, strategy, duration.Round(time.Millisecond))
 	} else {
 
 		file, err := os.Lstat(filepath.Join(workingDir, "node_modules"))
@@ -139,10 +193,16 @@ func (ip YarnInstallProcess) SetupModules(workingDir, currentModulesLayerPath, n
 // The build process here relies on yarn install ... --frozen-lockfile note that
 // even if we provide a node_modules directory we must run a 'yarn install' as
 // this is the ONLY way to rebuild native extensions.
-func (ip YarnInstallProcess) Execute(workingDir, modulesLayerPath string, launch bool) error {
+func (ip YarnInstallProcess) Execute(workingDir, modulesLayerPath, cacheLayerPath string, launch bool) error {
 	environment := os.Environ()
 	environment = append(environment, fmt.Sprintf("PATH=%s%c%s", os.Getenv("PATH"), os.PathListSeparator, filepath.Join("node_modules", ".bin")))
 
+	// Point yarn at the persistent download cache rather than the one in the
+	// ephemeral HOME
+	if cacheLayerPath != "" {
+		environment = append(environment, fmt.Sprintf("YARN_CACHE_FOLDER=%s", cacheLayerPath))
+	}
+
 	buffer := bytes.NewBuffer(nil)
 
 	err := ip.executable.Execute(pexec.Execution{
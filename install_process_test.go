@@ -25,6 +25,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 	context("ShouldRun", func() {
 		var (
 			workingDir     string
+			homeDir        string
 			executable     *fakes.Executable
 			installProcess yarninstall.YarnInstallProcess
 			summer         *fakes.Summer
@@ -40,6 +41,9 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 			err = os.WriteFile(filepath.Join(workingDir, "config-file"), []byte("hi"), os.ModePerm)
 			Expect(err).NotTo(HaveOccurred())
 
+			homeDir, err = os.MkdirTemp("", "home")
+			Expect(err).NotTo(HaveOccurred())
+
 			executable = &fakes.Executable{}
 			summer = &fakes.Summer{}
 			buffer = bytes.NewBuffer(nil)
@@ -50,13 +54,18 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				fmt.Fprintln(exec.Stderr, "undefined")
 				return nil
 			}
-			installProcess = yarninstall.NewYarnInstallProcess(executable, summer, scribe.NewEmitter(buffer))
+			installProcess = yarninstall.NewYarnInstallProcess(executable, summer, homeDir, scribe.NewEmitter(buffer))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(homeDir)).To(Succeed())
 		})
 
 		context("we should run yarn install when", func() {
 			context("there is no yarn.lock file in the workingDir", func() {
 				it("succeeds", func() {
-					run, sha, err := installProcess.ShouldRun(workingDir, map[string]interface{}{
+					run, sha, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{
 						"cache_sha": "some-sha",
 					})
 
@@ -75,7 +84,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("succeeds when sha is different", func() {
-					run, sha, err := installProcess.ShouldRun(workingDir, map[string]interface{}{
+					run, sha, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{
 						"cache_sha": "some-sha",
 					})
 					Expect(summer.SumCall.Receives.Paths[0]).To(Equal(filepath.Join(workingDir, "yarn.lock")))
@@ -92,8 +101,51 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 					Expect(execution.Dir).To(Equal(workingDir))
 				})
 
+				context("when the node installation, workspaces and home configuration are known", func() {
+					var nodeHome string
+
+					it.Before(func() {
+						var err error
+						nodeHome, err = os.MkdirTemp("", "node-home")
+						Expect(err).NotTo(HaveOccurred())
+						Expect(os.MkdirAll(filepath.Join(nodeHome, "include", "node"), os.ModePerm)).To(Succeed())
+						Expect(os.WriteFile(filepath.Join(nodeHome, "include", "node", "node_version.h"), []byte(`#define NODE_MAJOR_VERSION 20
+#define NODE_MINOR_VERSION 11
+#define NODE_PATCH_VERSION 1
+#define NODE_MODULE_VERSION 115
+`), 0600)).To(Succeed())
+						t.Setenv("NODE_HOME", nodeHome)
+
+						Expect(os.WriteFile(filepath.Join(homeDir, ".npmrc"), []byte("//registry.example.com/:_authToken=some-secret-token"), 0600)).To(Succeed())
+
+						Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"workspaces": ["packages/*"]}`), 0600)).To(Succeed())
+						Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "a"), os.ModePerm)).To(Succeed())
+						Expect(os.WriteFile(filepath.Join(workingDir, "packages", "a", "package.json"), []byte(`{}`), 0600)).To(Succeed())
+					})
+
+					it.After(func() {
+						Expect(os.RemoveAll(nodeHome)).To(Succeed())
+					})
+
+					it("includes them in the summed configuration", func() {
+						_, _, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{})
+						Expect(err).NotTo(HaveOccurred())
+
+						content, err := os.ReadFile(summer.SumCall.Receives.Paths[2])
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(content)).To(ContainSubstring("node-abi=115\n"))
+						Expect(string(content)).To(ContainSubstring("node-version=20.11.1\n"))
+						Expect(string(content)).To(ContainSubstring("yarn-version=1.22.22\n"))
+						Expect(string(content)).To(MatchRegexp(`arch=\w+\n`))
+						Expect(string(content)).To(MatchRegexp(`libc=(glibc|musl)\n`))
+						Expect(string(content)).To(MatchRegexp(`packages/a/package.json=sha256:[0-9a-f]{64}\n`))
+						Expect(string(content)).To(MatchRegexp(`~/.npmrc=sha256:[0-9a-f]{64}\n`))
+						Expect(string(content)).NotTo(ContainSubstring("some-secret-token"))
+					})
+				})
+
 				it("succeeds when sha is missing", func() {
-					run, sha, err := installProcess.ShouldRun(workingDir, map[string]interface{}{})
+					run, sha, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{})
 					Expect(run).To(BeTrue())
 					Expect(sha).To(Equal("some-other-sha"))
 					Expect(err).NotTo(HaveOccurred())
@@ -109,7 +161,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("does not run install", func() {
-					run, sha, err := installProcess.ShouldRun(workingDir, map[string]interface{}{
+					run, sha, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{
 						"cache_sha": "some-sha",
 					})
 					Expect(run).To(BeFalse())
@@ -129,7 +181,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 					})
 
 					it("fails", func() {
-						_, _, err := installProcess.ShouldRun(workingDir, map[string]interface{}{})
+						_, _, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{})
 						Expect(err).To(MatchError(ContainSubstring("unable to read yarn.lock file:")))
 					})
 				})
@@ -138,13 +190,16 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 					it.Before(func() {
 						Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(""), os.ModePerm)).To(Succeed())
 						executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
-							return errors.New("very bad error")
+							if execution.Args[0] == "config" {
+								return errors.New("very bad error")
+							}
+							return nil
 						}
-						installProcess = yarninstall.NewYarnInstallProcess(executable, summer, scribe.NewEmitter(bytes.NewBuffer(nil)))
+						installProcess = yarninstall.NewYarnInstallProcess(executable, summer, homeDir, scribe.NewEmitter(bytes.NewBuffer(nil)))
 					})
 
 					it("fails", func() {
-						_, _, err := installProcess.ShouldRun(workingDir, map[string]interface{}{})
+						_, _, err := installProcess.ShouldRun(workingDir, "1.22.22", map[string]interface{}{})
 						Expect(err).To(MatchError(ContainSubstring("very bad error")))
 						Expect(err).To(MatchError(ContainSubstring("failed to execute yarn config output")))
 					})
@@ -153,6 +208,75 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("CacheKey", func() {
+		var (
+			workingDir     string
+			homeDir        string
+			executable     *fakes.Executable
+			installProcess yarninstall.YarnInstallProcess
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("# yarn lockfile v1\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte("{}"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".yarnrc.yml"), []byte("nodeLinker: node-modules\n"), 0600)).To(Succeed())
+
+			homeDir, err = os.MkdirTemp("", "home")
+			Expect(err).NotTo(HaveOccurred())
+
+			t.Setenv("NODE_ENV", "production")
+
+			executable = &fakes.Executable{}
+			executable.ExecuteCall.Stub = func(exec pexec.Execution) error {
+				fmt.Fprintln(exec.Stdout, "some-config")
+				return nil
+			}
+
+			installProcess = yarninstall.NewYarnInstallProcess(executable, &fakes.Summer{}, homeDir, scribe.NewEmitter(bytes.NewBuffer(nil)))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+			Expect(os.RemoveAll(homeDir)).To(Succeed())
+		})
+
+		it("returns the inputs that ShouldRun hashes", func() {
+			components, err := installProcess.CacheKey(workingDir, "1.22.22")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(components).To(HaveKeyWithValue("yarn-version", "1.22.22"))
+			Expect(components).To(HaveKeyWithValue("NODE_ENV", "production"))
+			Expect(components).To(HaveKeyWithValue("yarn.lock", MatchRegexp(`^sha256:[0-9a-f]{64}$`)))
+			Expect(components).To(HaveKeyWithValue("package.json", MatchRegexp(`^sha256:[0-9a-f]{64}$`)))
+			Expect(components).To(HaveKeyWithValue("yarn config list", MatchRegexp(`^[0-9a-f]{64}$`)))
+			Expect(components).NotTo(HaveKey(".yarnrc.yml"))
+
+			Expect(executable.ExecuteCall.CallCount).To(Equal(1))
+			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"config", "list", "--silent"}))
+			Expect(executable.ExecuteCall.Receives.Execution.Dir).To(Equal(workingDir))
+		})
+
+		context("failure cases", func() {
+			context("when yarn config list fails", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(exec pexec.Execution) error {
+						return errors.New("very bad error")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := installProcess.CacheKey(workingDir, "1.22.22")
+					Expect(err).To(MatchError(ContainSubstring("failed to execute yarn config output")))
+					Expect(err).To(MatchError(ContainSubstring("very bad error")))
+				})
+			})
+		})
+	})
+
 	context("SetupModules", func() {
 		var (
 			workingDir              string
@@ -181,7 +305,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 
 			executable = &fakes.Executable{}
 
-			installProcess = yarninstall.NewYarnInstallProcess(executable, summer, scribe.NewEmitter(buffer))
+			installProcess = yarninstall.NewYarnInstallProcess(executable, summer, "some-home-dir", scribe.NewEmitter(buffer))
 		})
 
 		it.After(func() {
@@ -332,7 +456,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				return nil
 			}
 
-			installProcess = yarninstall.NewYarnInstallProcess(executable, summer, scribe.NewEmitter(buffer))
+			installProcess = yarninstall.NewYarnInstallProcess(executable, summer, "some-home-dir", scribe.NewEmitter(buffer))
 		})
 
 		it.After(func() {
@@ -342,7 +466,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 
 		context("when launch is false", func() {
 			it("executes yarn install", func() {
-				err := installProcess.Execute(workingDir, modulesLayerPath, false)
+				err := installProcess.Execute(workingDir, modulesLayerPath, "", false)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(executions).To(HaveLen(2))
@@ -372,9 +496,20 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when a cache layer is provided", func() {
+			it("points the yarn cache at the layer", func() {
+				err := installProcess.Execute(workingDir, modulesLayerPath, "/some/cache-layer", false)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(executions).To(HaveLen(2))
+				Expect(executions[0].Env).To(ContainElement("YARN_CACHE_FOLDER=/some/cache-layer"))
+				Expect(executions[1].Env).To(ContainElement("YARN_CACHE_FOLDER=/some/cache-layer"))
+			})
+		})
+
 		context("when launch is true", func() {
 			it("executes yarn install", func() {
-				err := installProcess.Execute(workingDir, modulesLayerPath, true)
+				err := installProcess.Execute(workingDir, modulesLayerPath, "", true)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(executions).To(HaveLen(2))
@@ -421,7 +556,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 			})
 
 			it("executes yarn install in offline mode", func() {
-				err := installProcess.Execute(workingDir, modulesLayerPath, true)
+				err := installProcess.Execute(workingDir, modulesLayerPath, "", true)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(executions).To(HaveLen(2))
@@ -462,7 +597,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("returns an error", func() {
-					err := installProcess.Execute(workingDir, modulesLayerPath, true)
+					err := installProcess.Execute(workingDir, modulesLayerPath, "", true)
 					Expect(err).To(MatchError(ContainSubstring("failed to execute yarn config")))
 					Expect(err).To(MatchError(ContainSubstring("error: yarn config failed")))
 				})
@@ -480,7 +615,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("returns an error", func() {
-					err := installProcess.Execute(workingDir, modulesLayerPath, true)
+					err := installProcess.Execute(workingDir, modulesLayerPath, "", true)
 					Expect(err).To(MatchError(ContainSubstring("failed to execute yarn config")))
 					Expect(err).To(MatchError(ContainSubstring("yarn config failed")))
 				})
@@ -503,7 +638,7 @@ func testInstallProcess(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("prints the execution output and returns an error", func() {
-					err := installProcess.Execute(workingDir, modulesLayerPath, true)
+					err := installProcess.Execute(workingDir, modulesLayerPath, "", true)
 					Expect(err).To(MatchError(ContainSubstring("failed to execute yarn install:")))
 					Expect(err).To(MatchError(ContainSubstring("yarn install failed")))
 
@@ -0,0 +1,111 @@
+package integration_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/occam"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+	. "github.com/paketo-buildpacks/occam/matchers"
+)
+
+func testBerryPnpmLinker(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect     = NewWithT(t).Expect
+		Eventually = NewWithT(t).Eventually
+
+		pack   occam.Pack
+		docker occam.Docker
+
+		image     occam.Image
+		container occam.Container
+		listing   occam.Container
+
+		name   string
+		source string
+
+		pullPolicy = "never"
+	)
+
+	it.Before(func() {
+		pack = occam.NewPack()
+		docker = occam.NewDocker()
+
+		var err error
+		name, err = occam.RandomName()
+		Expect(err).NotTo(HaveOccurred())
+
+		if settings.Extensions.UbiNodejsExtension.Online != "" {
+			pullPolicy = "always"
+		}
+	})
+
+	it.After(func() {
+		Expect(docker.Container.Remove.Execute(container.ID)).To(Succeed())
+		Expect(docker.Container.Remove.Execute(listing.ID)).To(Succeed())
+		Expect(docker.Image.Remove.Execute(image.ID)).To(Succeed())
+		Expect(docker.Volume.Remove.Execute(occam.CacheVolumeNames(name))).To(Succeed())
+		Expect(os.RemoveAll(source)).To(Succeed())
+	})
+
+	it("builds a working OCI image with the content-addressed store in the launch layer", func() {
+		var err error
+		source, err = occam.Source(filepath.Join("testdata", "berry_pnpm_linker"))
+		Expect(err).NotTo(HaveOccurred())
+
+		image, _, err = pack.Build.
+			WithExtensions(
+				settings.Extensions.UbiNodejsExtension.Online,
+			).
+			WithBuildpacks(
+				nodeURI,
+				yarnURI,
+				buildpackURI,
+				buildPlanURI,
+			).
+			WithPullPolicy(pullPolicy).
+			Execute(name, source)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the packages resolve through the relative symlinks into the .store
+		container, err = docker.Container.Run.
+			WithCommand("node server.js").
+			WithEnv(map[string]string{"PORT": "8080"}).
+			WithPublish("8080").
+			WithPublishAll().
+			Execute(image.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(container).Should(BeAvailable())
+
+		response, err := http.Get(fmt.Sprintf("http://localhost:%s", container.HostPort("8080")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		content, err := io.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("Hello, World!"))
+
+		listing, err = docker.Container.Run.
+			WithCommand(fmt.Sprintf("ls -al /layers/%s/launch-modules/node_modules",
+				strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"))).
+			Execute(image.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() string {
+			cLogs, err := docker.Container.Logs.Execute(listing.ID)
+			Expect(err).NotTo(HaveOccurred())
+			return cLogs.String()
+		}).Should(And(
+			ContainSubstring(".store"),
+			MatchRegexp(`leftpad -> \.store/leftpad-`),
+		))
+	})
+}
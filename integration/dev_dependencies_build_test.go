@@ -112,6 +112,7 @@ func testDevDependenciesDuringBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(string(contents)).To(ContainSubstring(`"name": "leftpad"`))
+			Expect(string(contents)).NotTo(ContainSubstring(`"name": "chalk"`))
 
 			// check the build SBOM file to make sure it has an entry for an app node module
 			contents, err = os.ReadFile(filepath.Join(sbomDir, "sbom", "build", strings.ReplaceAll(buildpackInfo.Buildpack.ID, "/", "_"), "build-modules", "sbom.cdx.json"))
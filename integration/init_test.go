@@ -115,6 +115,7 @@ func TestIntegration(t *testing.T) {
 	SetDefaultEventuallyTimeout(10 * time.Second)
 
 	suite := spec.New("Integration", spec.Parallel(), spec.Report(report.Terminal{}))
+	suite("BerryPnpmLinker", testBerryPnpmLinker)
 	suite("Caching", testCaching)
 	suite("DevDependenciesDuringBuild", testDevDependenciesDuringBuild)
 	suite("Logging", testLogging)
@@ -0,0 +1,173 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// cacheKeyLabels names the components that hold a value.
+var cacheKeyLabels = map[string]string{
+	"arch":         "architecture",
+	"libc":         "libc",
+	"node-abi":     "node ABI",
+	"node-version": "node version",
+	"yarn-version": "yarn version",
+	"NODE_ENV":     "NODE_ENV",
+}
+
+// CacheKeyComponents returns the inputs of an install.
+func CacheKeyComponents(projectPath, homeDir, yarnVersion string) (map[string]string, error) {
+	components, err := CacheKeyInputs(projectPath, homeDir, yarnVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{YarnLock, "package.json", YarnrcYml} {
+		sum, err := fileSHA256(filepath.Join(projectPath, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		components[name] = sum
+	}
+
+	components["NODE_ENV"] = os.Getenv("NODE_ENV")
+
+	return components, nil
+}
+
+// ExplainInvalidation describes the components that changed since the
+// previous modules layer.
+func ExplainInvalidation(metadata map[string]interface{}, components map[string]string, snapshotPath, lockfilePath string) []string {
+	previous, ok := previousCacheKey(metadata)
+	if !ok {
+		return nil
+	}
+
+	keys := map[string]bool{}
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range components {
+		keys[key] = true
+	}
+
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var reasons []string
+	for _, key := range sorted {
+		before, hadBefore := previous[key]
+		after, hasAfter := components[key]
+		if before == after && hadBefore == hasAfter {
+			continue
+		}
+
+		if label, ok := cacheKeyLabels[key]; ok {
+			reasons = append(reasons, fmt.Sprintf("%s %s → %s", label, valueOrNone(before), valueOrNone(after)))
+			continue
+		}
+
+		switch {
+		case !hadBefore:
+			reasons = append(reasons, fmt.Sprintf("%s added", key))
+		case !hasAfter:
+			reasons = append(reasons, fmt.Sprintf("%s removed", key))
+		case key == YarnLock:
+			reasons = append(reasons, describeLockfileChange(metadata, snapshotPath, lockfilePath))
+		default:
+			reasons = append(reasons, fmt.Sprintf("%s changed", key))
+		}
+	}
+
+	// The remaining inputs, like the output of 'yarn config list', are only
+	// part of the checksum
+	if len(reasons) == 0 {
+		reasons = append(reasons, "yarn configuration changed")
+	}
+
+	return reasons
+}
+
+func logInvalidation(logger scribe.Emitter, metadata map[string]interface{}, components map[string]string, snapshotPath, projectPath string) {
+	reasons := ExplainInvalidation(metadata, components, snapshotPath, filepath.Join(projectPath, YarnLock))
+	if len(reasons) == 0 {
+		return
+	}
+
+	logger.Subprocess("Cached layer is out of date:")
+	for _, reason := range reasons {
+		logger.Action(reason)
+	}
+	logger.Break()
+}
+
+func previousCacheKey(metadata map[string]interface{}) (map[string]string, bool) {
+	switch key := metadata["cache_key"].(type) {
+	case map[string]string:
+		return key, true
+	case map[string]interface{}:
+		previous := map[string]string{}
+		for name, value := range key {
+			previous[name] = fmt.Sprint(value)
+		}
+		return previous, true
+	default:
+		return nil, false
+	}
+}
+
+func describeLockfileChange(metadata map[string]interface{}, snapshotPath, currentPath string) string {
+	previous, ok := ReadLockfileSnapshot(snapshotPath, metadata)
+	if !ok {
+		return "yarn.lock changed"
+	}
+
+	current, err := lockfile.Parse(currentPath)
+	if err != nil {
+		return "yarn.lock changed"
+	}
+
+	changes := lockfile.Diff(previous, current)
+
+	var parts []string
+	for _, part := range []struct {
+		format string
+		count  int
+	}{
+		{"+%d added", len(changes.Added)},
+		{"-%d removed", len(changes.Removed)},
+		{"~%d upgraded", len(changes.Upgraded)},
+		{"%d downgraded", len(changes.Downgraded)},
+	} {
+		if part.count > 0 {
+			parts = append(parts, fmt.Sprintf(part.format, part.count))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "yarn.lock changed"
+	}
+
+	return fmt.Sprintf("yarn.lock changed (%s)", strings.Join(parts, ", "))
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+
+	return value
+}
@@ -0,0 +1,104 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testInvalidation(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectDir string
+	)
+
+	it.Before(func() {
+		var err error
+		projectDir, err = os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+express@^4.18.0:
+  version "4.18.2"
+
+leftpad@~0.0.1:
+  version "0.0.1"
+
+lodash@^4.17.21:
+  version "4.17.21"
+`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(projectDir)).To(Succeed())
+	})
+
+	context("ExplainInvalidation", func() {
+		it("describes each component that changed", func() {
+			snapshotPath := filepath.Join(projectDir, "snapshot.json")
+			Expect(os.WriteFile(snapshotPath, []byte(`{"express":["4.17.3"],"leftpad":["0.0.1"]}`), 0600)).To(Succeed())
+
+			reasons := yarninstall.ExplainInvalidation(map[string]interface{}{
+				"cache_key": map[string]interface{}{
+					"node-version":            "18.19.0",
+					"yarn.lock":               "sha256:aaa",
+					"package.json":            "sha256:bbb",
+					"packages/a/package.json": "sha256:ccc",
+					"~/.npmrc":                "sha256:ddd",
+				},
+				"lockfile_sha": "sha256:c5b4e1439e79a1769d863d9cb879babbe51d9d1b6b2183068dbd2ddd233da5e3",
+			}, map[string]string{
+				"node-version":            "20.11.1",
+				"yarn.lock":               "sha256:eee",
+				"package.json":            "sha256:bbb",
+				"packages/b/package.json": "sha256:fff",
+				"~/.npmrc":                "sha256:ggg",
+			}, snapshotPath, filepath.Join(projectDir, "yarn.lock"))
+
+			Expect(reasons).To(Equal([]string{
+				"node version 18.19.0 → 20.11.1",
+				"packages/a/package.json removed",
+				"packages/b/package.json added",
+				"yarn.lock changed (+1 added, ~1 upgraded)",
+				"~/.npmrc changed",
+			}))
+		})
+
+		context("when the previous layer did not record a lockfile snapshot", func() {
+			it("reports that the lockfile changed", func() {
+				reasons := yarninstall.ExplainInvalidation(map[string]interface{}{
+					"cache_key": map[string]interface{}{"yarn.lock": "sha256:aaa"},
+				}, map[string]string{"yarn.lock": "sha256:bbb"}, filepath.Join(projectDir, "snapshot.json"), filepath.Join(projectDir, "yarn.lock"))
+
+				Expect(reasons).To(Equal([]string{"yarn.lock changed"}))
+			})
+		})
+
+		context("when none of the recorded components changed", func() {
+			it("attributes the change to the yarn configuration", func() {
+				reasons := yarninstall.ExplainInvalidation(map[string]interface{}{
+					"cache_key": map[string]interface{}{"arch": "amd64"},
+				}, map[string]string{"arch": "amd64"}, "", "")
+
+				Expect(reasons).To(Equal([]string{"yarn configuration changed"}))
+			})
+		})
+
+		context("when the previous layer did not record its components", func() {
+			it("returns nothing", func() {
+				reasons := yarninstall.ExplainInvalidation(map[string]interface{}{
+					"cache_sha": "some-sha",
+				}, map[string]string{"arch": "amd64"}, "", "")
+
+				Expect(reasons).To(BeEmpty())
+			})
+		})
+	})
+}
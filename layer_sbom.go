@@ -0,0 +1,194 @@
+package yarninstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// sbomIndexFile records the install and files of a persisted SBOM.
+const sbomIndexFile = "index.json"
+
+type sbomIndex struct {
+	Key     string            `json:"key"`
+	Formats map[string]string `json:"formats"`
+}
+
+// PersistedSBOM is an SBOM that was formatted once and kept in memory.
+type PersistedSBOM struct {
+	extensions []string
+	content    [][]byte
+}
+
+// Formats returns the SBOM in every format it was persisted in.
+func (s PersistedSBOM) Formats() []packit.SBOMFormat {
+	var formats []packit.SBOMFormat
+	for i, extension := range s.extensions {
+		formats = append(formats, packit.SBOMFormat{
+			Extension: extension,
+			Content:   bytes.NewReader(s.content[i]),
+		})
+	}
+
+	return formats
+}
+
+// LayerSBOM is the SBOM of a modules layer along with the yarn dependency
+// graph of its packages.
+type LayerSBOM struct {
+	SBOM  sbom.SBOM
+	graph dependencyGraph
+}
+
+// PersistSBOM writes the SBOM of a modules layer into dir.
+func PersistSBOM(bom LayerSBOM, dir, key string, mediaTypes []string) (PersistedSBOM, error) {
+	formatter, err := bom.SBOM.InFormats(mediaTypes...)
+	if err != nil {
+		return PersistedSBOM{}, err
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return PersistedSBOM{}, fmt.Errorf("failed to remove persisted SBOM: %w", err)
+	}
+
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return PersistedSBOM{}, fmt.Errorf("failed to create SBOM directory: %w", err)
+	}
+
+	var persisted PersistedSBOM
+	index := sbomIndex{Key: key, Formats: map[string]string{}}
+	for i, format := range formatter.Formats() {
+		content, err := io.ReadAll(format.Content)
+		if err != nil {
+			return PersistedSBOM{}, fmt.Errorf("failed to format SBOM: %w", err)
+		}
+
+		content, err = bom.graph.annotate(format.Extension, content)
+		if err != nil {
+			return PersistedSBOM{}, err
+		}
+
+		name := fmt.Sprintf("sbom.%s", format.Extension)
+		err = os.WriteFile(filepath.Join(dir, name), content, 0644)
+		if err != nil {
+			return PersistedSBOM{}, fmt.Errorf("failed to persist SBOM: %w", err)
+		}
+
+		index.Formats[mediaTypes[i]] = name
+		persisted.extensions = append(persisted.extensions, format.Extension)
+		persisted.content = append(persisted.content, content)
+	}
+
+	content, err := json.Marshal(index)
+	if err != nil {
+		return PersistedSBOM{}, fmt.Errorf("failed to encode SBOM index: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, sbomIndexFile), content, 0644)
+	if err != nil {
+		return PersistedSBOM{}, fmt.Errorf("failed to persist SBOM: %w", err)
+	}
+
+	return persisted, nil
+}
+
+// LoadSBOM reads the SBOM that PersistSBOM wrote for the given key.
+func LoadSBOM(dir, key string, mediaTypes []string) (PersistedSBOM, bool, error) {
+	content, err := os.ReadFile(filepath.Join(dir, sbomIndexFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return PersistedSBOM{}, false, nil
+		}
+		return PersistedSBOM{}, false, fmt.Errorf("failed to read SBOM index: %w", err)
+	}
+
+	var index sbomIndex
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return PersistedSBOM{}, false, fmt.Errorf("failed to parse SBOM index: %w", err)
+	}
+
+	if index.Key != key {
+		return PersistedSBOM{}, false, nil
+	}
+
+	var persisted PersistedSBOM
+	for _, mediaType := range mediaTypes {
+		name, ok := index.Formats[mediaType]
+		if !ok {
+			return PersistedSBOM{}, false, nil
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return PersistedSBOM{}, false, nil
+			}
+			return PersistedSBOM{}, false, fmt.Errorf("failed to read persisted SBOM: %w", err)
+		}
+
+		persisted.extensions = append(persisted.extensions, strings.TrimPrefix(name, "sbom."))
+		persisted.content = append(persisted.content, content)
+	}
+
+	return persisted, true, nil
+}
+
+func generateLayerSBOM(generator SBOMGenerator, clock chronos.Clock, logger scribe.Emitter, projectPath, layerPath, sbomDir, key string, mediaTypes []string) (PersistedSBOM, error) {
+	logger.GeneratingSBOM(layerPath)
+
+	var bom LayerSBOM
+	duration, err := clock.Measure(func() error {
+		var err error
+		bom, err = generator.Generate(projectPath, layerPath)
+		return err
+	})
+	if err != nil {
+		return PersistedSBOM{}, err
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	logger.FormattingSBOM(mediaTypes...)
+	return PersistSBOM(bom, sbomDir, key, mediaTypes)
+}
+
+// cachedLayerSBOM returns the persisted SBOM of a reused modules layer.
+func cachedLayerSBOM(generator SBOMGenerator, clock chronos.Clock, logger scribe.Emitter, projectPath, layerPath, sbomDir, key string, mediaTypes []string) (packit.SBOMFormatter, error) {
+	persisted, ok, err := LoadSBOM(sbomDir, key, mediaTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		logger.Subprocess("Reusing SBOM of the cached layer")
+		logger.Break()
+		return persisted, nil
+	}
+
+	restored, err := fs.Exists(layerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !restored {
+		return nil, nil
+	}
+
+	return generateLayerSBOM(generator, clock, logger, projectPath, layerPath, sbomDir, key, mediaTypes)
+}
@@ -0,0 +1,122 @@
+package yarninstall_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLayerSBOM(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		sbomDir    string
+		mediaTypes []string
+	)
+
+	it.Before(func() {
+		layersDir, err := os.MkdirTemp("", "layers")
+		Expect(err).NotTo(HaveOccurred())
+		sbomDir = filepath.Join(layersDir, "modules-sbom", "build-modules")
+
+		mediaTypes = []string{sbom.CycloneDXFormat, sbom.SyftFormat}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(filepath.Dir(filepath.Dir(sbomDir)))).To(Succeed())
+	})
+
+	context("PersistSBOM", func() {
+		it("writes every format and returns an SBOM that can be read more than once", func() {
+			persisted, err := yarninstall.PersistSBOM(yarninstall.LayerSBOM{}, sbomDir, "some-key", mediaTypes)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(filepath.Join(sbomDir, "sbom.cdx.json")).To(BeARegularFile())
+			Expect(filepath.Join(sbomDir, "sbom.syft.json")).To(BeARegularFile())
+
+			for i := 0; i < 2; i++ {
+				formats := persisted.Formats()
+				Expect(formats).To(HaveLen(2))
+				Expect(formats[0].Extension).To(Equal("cdx.json"))
+				Expect(formats[1].Extension).To(Equal("syft.json"))
+
+				content, err := io.ReadAll(formats[0].Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"bomFormat": "CycloneDX"`))
+			}
+		})
+
+		context("when a format is not supported", func() {
+			it("returns an error", func() {
+				_, err := yarninstall.PersistSBOM(yarninstall.LayerSBOM{}, sbomDir, "some-key", []string{"random-format"})
+				Expect(err).To(MatchError("unsupported SBOM format: 'random-format'"))
+			})
+		})
+	})
+
+	context("LoadSBOM", func() {
+		it.Before(func() {
+			_, err := yarninstall.PersistSBOM(yarninstall.LayerSBOM{}, sbomDir, "some-key", mediaTypes)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("reads the persisted formats", func() {
+			persisted, ok, err := yarninstall.LoadSBOM(sbomDir, "some-key", []string{sbom.SyftFormat})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			formats := persisted.Formats()
+			Expect(formats).To(HaveLen(1))
+			Expect(formats[0].Extension).To(Equal("syft.json"))
+
+			expected, err := os.ReadFile(filepath.Join(sbomDir, "sbom.syft.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := io.ReadAll(formats[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal(expected))
+		})
+
+		context("when the SBOM describes another install", func() {
+			it("reports that there is none", func() {
+				_, ok, err := yarninstall.LoadSBOM(sbomDir, "some-other-key", mediaTypes)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		context("when a format was not persisted", func() {
+			it("reports that there is none", func() {
+				_, ok, err := yarninstall.LoadSBOM(sbomDir, "some-key", []string{sbom.SPDXFormat})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		context("when nothing was persisted", func() {
+			it("reports that there is none", func() {
+				_, ok, err := yarninstall.LoadSBOM(filepath.Join(sbomDir, "missing"), "some-key", mediaTypes)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		context("when the index is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(sbomDir, "index.json"), []byte("%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, _, err := yarninstall.LoadSBOM(sbomDir, "some-key", mediaTypes)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse SBOM index")))
+			})
+		})
+	})
+}
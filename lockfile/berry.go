@@ -0,0 +1,88 @@
+package lockfile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BerryMetadataKey is the top-level metadata key of Berry lockfiles.
+const BerryMetadataKey = "__metadata"
+
+// Metadata is the content of the __metadata block of a Berry lockfile.
+type Metadata struct {
+	Version  string `yaml:"version"`
+	CacheKey string `yaml:"cacheKey"`
+}
+
+// DependencyMeta holds the dependenciesMeta settings of a dependency.
+type DependencyMeta struct {
+	Optional  bool `yaml:"optional"`
+	Built     bool `yaml:"built"`
+	Unplugged bool `yaml:"unplugged"`
+}
+
+type berryEntry struct {
+	Version          string                    `yaml:"version"`
+	Resolution       string                    `yaml:"resolution"`
+	Checksum         string                    `yaml:"checksum"`
+	LinkType         string                    `yaml:"linkType"`
+	Dependencies     map[string]string         `yaml:"dependencies"`
+	DependenciesMeta map[string]DependencyMeta `yaml:"dependenciesMeta"`
+}
+
+// ParseBerry parses a Yarn Berry (v2+) lockfile.
+func ParseBerry(r io.Reader) (Lockfile, error) {
+	var document map[string]yaml.Node
+	err := yaml.NewDecoder(r).Decode(&document)
+	if err != nil && err != io.EOF {
+		return Lockfile{}, err
+	}
+
+	metadataNode, ok := document[BerryMetadataKey]
+	if !ok {
+		return Lockfile{}, fmt.Errorf("missing %s block", BerryMetadataKey)
+	}
+
+	lockfile := Lockfile{Format: FormatBerry}
+
+	err = metadataNode.Decode(&lockfile.Metadata)
+	if err != nil {
+		return Lockfile{}, fmt.Errorf("invalid %s block: %w", BerryMetadataKey, err)
+	}
+
+	for key, node := range document {
+		if key == BerryMetadataKey {
+			continue
+		}
+
+		var entry berryEntry
+		err = node.Decode(&entry)
+		if err != nil {
+			return Lockfile{}, fmt.Errorf("invalid entry %q: %w", key, err)
+		}
+
+		var descriptors []string
+		for _, descriptor := range strings.Split(key, ",") {
+			descriptors = append(descriptors, strings.TrimSpace(descriptor))
+		}
+
+		name, _ := SplitDescriptor(descriptors[0])
+		lockfile.Packages = append(lockfile.Packages, Package{
+			Name:             name,
+			Version:          entry.Version,
+			Descriptors:      descriptors,
+			Resolved:         entry.Resolution,
+			Integrity:        entry.Checksum,
+			LinkType:         entry.LinkType,
+			Dependencies:     entry.Dependencies,
+			DependenciesMeta: entry.DependenciesMeta,
+		})
+	}
+
+	lockfile.buildIndex()
+
+	return lockfile, nil
+}
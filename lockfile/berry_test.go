@@ -0,0 +1,129 @@
+package lockfile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBerry(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseBerry", func() {
+		it("parses the metadata block and the entries", func() {
+			content := `# This file is generated by running "yarn install" inside your project.
+# Manual changes might be lost - proceed with caution!
+
+__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"fsevents@npm:~2.3.2":
+  version: 2.3.3
+  resolution: "fsevents@npm:2.3.3"
+  dependencies:
+    node-gyp: "npm:latest"
+  checksum: 10c0/a1f0c6cd7e5aa64aa4c2e1e1b8ab1c7eeaa1d9ba0c6bd2bfbc2bbbe4b4a48a6e1e2cb38e8d1dbbf2a2af7e98ed2f3a2e5d7a42b8ce53e4b8e8fb09fde2d31af7
+  languageName: node
+  linkType: hard
+
+"leftpad@npm:~0.0.1, leftpad@npm:^0.0.1":
+  version: 0.0.1
+  resolution: "leftpad@npm:0.0.1"
+  checksum: 10c0/3e7a91c8e8ae7e0ecb15ebc4c6e4b20fcf53d3fd8de06c52b8d0d1a5c2f8a7c9a9d4f1f3e5b20e2b5b0ad1d54f1e6f7c9c3fcbf4b7b5bc0e5d1f4a7e5c2a5d8b
+  languageName: node
+  linkType: hard
+
+"my-app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "my-app@workspace:."
+  dependencies:
+    fsevents: "npm:~2.3.2"
+    leftpad: "npm:~0.0.1"
+  dependenciesMeta:
+    fsevents:
+      optional: true
+  languageName: unknown
+  linkType: soft
+`
+
+			lf, err := lockfile.ParseBerry(strings.NewReader(content))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lf.Format).To(Equal(lockfile.FormatBerry))
+			Expect(lf.Metadata).To(Equal(lockfile.Metadata{
+				Version:  "8",
+				CacheKey: "10c0",
+			}))
+			Expect(lf.Packages).To(HaveLen(3))
+
+			Expect(lf.Packages[1]).To(Equal(lockfile.Package{
+				Name:        "leftpad",
+				Version:     "0.0.1",
+				Descriptors: []string{"leftpad@npm:~0.0.1", "leftpad@npm:^0.0.1"},
+				Resolved:    "leftpad@npm:0.0.1",
+				Integrity:   "10c0/3e7a91c8e8ae7e0ecb15ebc4c6e4b20fcf53d3fd8de06c52b8d0d1a5c2f8a7c9a9d4f1f3e5b20e2b5b0ad1d54f1e6f7c9c3fcbf4b7b5bc0e5d1f4a7e5c2a5d8b",
+				LinkType:    "hard",
+			}))
+
+			workspace := lf.Packages[2]
+			Expect(workspace.Name).To(Equal("my-app"))
+			Expect(workspace.LinkType).To(Equal("soft"))
+			Expect(workspace.Dependencies).To(Equal(map[string]string{
+				"fsevents": "npm:~2.3.2",
+				"leftpad":  "npm:~0.0.1",
+			}))
+			Expect(workspace.DependenciesMeta).To(Equal(map[string]lockfile.DependencyMeta{
+				"fsevents": {Optional: true},
+			}))
+
+			var names []string
+			for _, dependency := range lf.Dependencies(workspace) {
+				names = append(names, dependency.Name)
+			}
+			Expect(names).To(Equal([]string{"fsevents", "leftpad"}))
+		})
+
+		context("failure cases", func() {
+			context("when the metadata block is missing", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseBerry(strings.NewReader("\"leftpad@npm:~0.0.1\":\n  version: 0.0.1\n"))
+					Expect(err).To(MatchError("missing __metadata block"))
+				})
+			})
+
+			context("when an entry is malformed", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseBerry(strings.NewReader("__metadata:\n  version: 8\n\"leftpad@npm:~0.0.1\": some-string\n"))
+					Expect(err).To(MatchError(ContainSubstring(`invalid entry "leftpad@npm:~0.0.1"`)))
+				})
+			})
+
+			context("when the document is not valid YAML", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseBerry(strings.NewReader("__metadata: [\n"))
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	context("DetectFormat", func() {
+		it("recognises Berry lockfiles by their metadata block", func() {
+			Expect(lockfile.DetectFormat(strings.NewReader("# comment\n\n__metadata:\n  version: 8\n"))).To(Equal(lockfile.FormatBerry))
+		})
+
+		it("recognises Classic lockfiles by their header", func() {
+			Expect(lockfile.DetectFormat(strings.NewReader("# THIS IS AN AUTOGENERATED FILE.\n# yarn lockfile v1\n"))).To(Equal(lockfile.FormatClassic))
+		})
+
+		it("returns an empty string for anything else", func() {
+			Expect(lockfile.DetectFormat(strings.NewReader("leftpad@~0.0.1:\n  version \"0.0.1\"\n"))).To(Equal(""))
+			Expect(lockfile.DetectFormat(strings.NewReader(""))).To(Equal(""))
+		})
+	})
+}
@@ -0,0 +1,182 @@
+package lockfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseClassic parses a Yarn Classic (v1) lockfile.
+func ParseClassic(r io.Reader) (Lockfile, error) {
+	lockfile := Lockfile{Format: FormatClassic}
+
+	var (
+		current *Package
+		section map[string]string
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(trimmed)
+		if indent%2 != 0 {
+			return Lockfile{}, fmt.Errorf("line %d: invalid indentation", number)
+		}
+
+		tokens, opensBlock, err := tokenizeClassicLine(trimmed)
+		if err != nil {
+			return Lockfile{}, fmt.Errorf("line %d: %w", number, err)
+		}
+
+		switch indent / 2 {
+		case 0:
+			if !opensBlock || len(tokens) == 0 {
+				return Lockfile{}, fmt.Errorf("line %d: expected package descriptors", number)
+			}
+
+			if current != nil {
+				lockfile.Packages = append(lockfile.Packages, *current)
+			}
+
+			name, _ := SplitDescriptor(tokens[0])
+			current = &Package{
+				Name:        name,
+				Descriptors: tokens,
+			}
+			section = nil
+
+		case 1:
+			if current == nil {
+				return Lockfile{}, fmt.Errorf("line %d: unexpected indentation", number)
+			}
+
+			section = nil
+			if opensBlock {
+				if len(tokens) != 1 {
+					return Lockfile{}, fmt.Errorf("line %d: expected a single key", number)
+				}
+
+				switch tokens[0] {
+				case "dependencies":
+					current.Dependencies = map[string]string{}
+					section = current.Dependencies
+				case "optionalDependencies":
+					current.OptionalDependencies = map[string]string{}
+					section = current.OptionalDependencies
+				default:
+					section = map[string]string{}
+				}
+				continue
+			}
+
+			if len(tokens) != 2 {
+				return Lockfile{}, fmt.Errorf("line %d: expected a key and a value", number)
+			}
+
+			switch tokens[0] {
+			case "version":
+				current.Version = tokens[1]
+			case "resolved":
+				current.Resolved = tokens[1]
+			case "integrity":
+				current.Integrity = tokens[1]
+			}
+
+		case 2:
+			if section == nil {
+				return Lockfile{}, fmt.Errorf("line %d: unexpected indentation", number)
+			}
+
+			if opensBlock || len(tokens) != 2 {
+				return Lockfile{}, fmt.Errorf("line %d: expected a dependency name and range", number)
+			}
+
+			section[tokens[0]] = tokens[1]
+
+		default:
+			// Nothing that the buildpack reads is nested this deeply, but
+			// yarn does not forbid it, so the content is skipped.
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return Lockfile{}, err
+	}
+
+	if current != nil {
+		lockfile.Packages = append(lockfile.Packages, *current)
+	}
+
+	lockfile.buildIndex()
+
+	return lockfile, nil
+}
+
+// tokenizeClassicLine splits a line of a v1 lockfile into its tokens.
+func tokenizeClassicLine(line string) ([]string, bool, error) {
+	var (
+		tokens     []string
+		opensBlock bool
+	)
+
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == ',':
+			i++
+
+		case c == ':':
+			if strings.TrimSpace(line[i+1:]) != "" {
+				return nil, false, fmt.Errorf("unexpected content after ':'")
+			}
+			opensBlock = true
+			i = len(line)
+
+		case c == '"':
+			end := i + 1
+			for ; end < len(line); end++ {
+				if line[end] == '\\' {
+					end++
+					continue
+				}
+				if line[end] == '"' {
+					break
+				}
+			}
+			if end >= len(line) {
+				return nil, false, fmt.Errorf("unterminated string")
+			}
+
+			token, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid string %s: %w", line[i:end+1], err)
+			}
+			tokens = append(tokens, token)
+			i = end + 1
+
+		default:
+			end := i
+			for ; end < len(line); end++ {
+				if line[end] == ' ' || line[end] == ',' || (line[end] == ':' && strings.TrimSpace(line[end+1:]) == "") {
+					break
+				}
+			}
+			tokens = append(tokens, line[i:end])
+			i = end
+		}
+	}
+
+	return tokens, opensBlock, nil
+}
@@ -0,0 +1,133 @@
+package lockfile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testClassic(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseClassic", func() {
+		it("parses entries, descriptors and dependencies", func() {
+			content := `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.10.4":
+  version "7.12.13"
+  resolved "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.12.13.tgz#dcfc826beef65e75c50e21d3837d7d95798dd658"
+  integrity sha512-HV1Cm0Q3ZrpCR93tkWOYiuYIgLxZXZFVG2VgK+MBWjUqZTundupbfx2aXarXuw5Ko5aMcjtJgbSs4vUGBS5v6g==
+  dependencies:
+    "@babel/highlight" "^7.12.13"
+
+"@babel/highlight@^7.12.13":
+  version "7.13.10"
+  resolved "https://registry.yarnpkg.com/@babel/highlight/-/highlight-7.13.10.tgz#a8b2a66148f5b27d666b15d81774347a731d52d1"
+  integrity sha512-5aPpe5XQPzflQrFwL1/QoeHkP2MsA4JCntcXHRhEsdsfPVkvPi2w7Qix4iV7t5S/oC9OodGrggd8aco1g3SZFg==
+  dependencies:
+    js-tokens "^4.0.0"
+  optionalDependencies:
+    fsevents "~2.3.1"
+
+js-tokens@^4.0.0:
+  version "4.0.0"
+  resolved "https://registry.yarnpkg.com/js-tokens/-/js-tokens-4.0.0.tgz#19203fb59991df98e3a287050d4647cdeaf32499"
+  integrity sha512-RdJUflcE3cUzKiMqQgsCu06FPu9UdIJO0beYbPhHN4k6apgJtifcoCtT9bcxOpYBtpD2kCM6Sbzg4CausW/PKQ==
+
+leftpad@~0.0.1:
+  version "0.0.1"
+  resolved "https://registry.yarnpkg.com/leftpad/-/leftpad-0.0.1.tgz#86b1a4de4face180ac545a83f1503523d8fed115"
+  integrity sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=
+`
+
+			lf, err := lockfile.ParseClassic(strings.NewReader(content))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lf.Format).To(Equal(lockfile.FormatClassic))
+			Expect(lf.Packages).To(HaveLen(4))
+
+			Expect(lf.Packages[0]).To(Equal(lockfile.Package{
+				Name:        "@babel/code-frame",
+				Version:     "7.12.13",
+				Descriptors: []string{"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.10.4"},
+				Resolved:    "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.12.13.tgz#dcfc826beef65e75c50e21d3837d7d95798dd658",
+				Integrity:   "sha512-HV1Cm0Q3ZrpCR93tkWOYiuYIgLxZXZFVG2VgK+MBWjUqZTundupbfx2aXarXuw5Ko5aMcjtJgbSs4vUGBS5v6g==",
+				Dependencies: map[string]string{
+					"@babel/highlight": "^7.12.13",
+				},
+			}))
+
+			Expect(lf.Packages[1].Name).To(Equal("@babel/highlight"))
+			Expect(lf.Packages[1].OptionalDependencies).To(Equal(map[string]string{
+				"fsevents": "~2.3.1",
+			}))
+
+			Expect(lf.Packages[3]).To(Equal(lockfile.Package{
+				Name:        "leftpad",
+				Version:     "0.0.1",
+				Descriptors: []string{"leftpad@~0.0.1"},
+				Resolved:    "https://registry.yarnpkg.com/leftpad/-/leftpad-0.0.1.tgz#86b1a4de4face180ac545a83f1503523d8fed115",
+				Integrity:   "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=",
+			}))
+		})
+
+		it("parses unquoted keys that share an entry", func() {
+			content := `# yarn lockfile v1
+
+debug@2.6.9, debug@^2.2.0:
+  version "2.6.9"
+  dependencies:
+    ms "2.0.0"
+`
+
+			lf, err := lockfile.ParseClassic(strings.NewReader(content))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lf.Packages).To(HaveLen(1))
+			Expect(lf.Packages[0].Descriptors).To(Equal([]string{"debug@2.6.9", "debug@^2.2.0"}))
+			Expect(lf.Packages[0].Dependencies).To(Equal(map[string]string{"ms": "2.0.0"}))
+		})
+
+		it("parses an empty lockfile", func() {
+			lf, err := lockfile.ParseClassic(strings.NewReader("# yarn lockfile v1\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lf.Packages).To(BeEmpty())
+		})
+
+		context("failure cases", func() {
+			context("when the indentation is invalid", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseClassic(strings.NewReader("leftpad@~0.0.1:\n   version \"0.0.1\"\n"))
+					Expect(err).To(MatchError("line 2: invalid indentation"))
+				})
+			})
+
+			context("when an entry field appears before any entry", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseClassic(strings.NewReader("  version \"0.0.1\"\n"))
+					Expect(err).To(MatchError("line 1: unexpected indentation"))
+				})
+			})
+
+			context("when a top-level line is not a list of descriptors", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseClassic(strings.NewReader("leftpad \"0.0.1\"\n"))
+					Expect(err).To(MatchError("line 1: expected package descriptors"))
+				})
+			})
+
+			context("when a string is unterminated", func() {
+				it("returns an error", func() {
+					_, err := lockfile.ParseClassic(strings.NewReader("leftpad@~0.0.1:\n  version \"0.0.1\n"))
+					Expect(err).To(MatchError("line 2: unterminated string"))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package lockfile
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Change is a package whose version differs between two lockfiles.
+type Change struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// Changes lists the differences between two lockfiles, each sorted by name.
+type Changes struct {
+	Added      []Change `json:"added"`
+	Removed    []Change `json:"removed"`
+	Upgraded   []Change `json:"upgraded"`
+	Downgraded []Change `json:"downgraded"`
+}
+
+// Empty reports whether the lockfiles resolve the same package versions.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Upgraded) == 0 && len(c.Downgraded) == 0
+}
+
+// Diff compares the package versions of two lockfiles.
+func Diff(previous, current Lockfile) Changes {
+	previousVersions := packageVersions(previous)
+	currentVersions := packageVersions(current)
+
+	var names []string
+	for name := range previousVersions {
+		names = append(names, name)
+	}
+	for name := range currentVersions {
+		if _, ok := previousVersions[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes Changes
+	for _, name := range names {
+		removed := subtract(previousVersions[name], currentVersions[name])
+		added := subtract(currentVersions[name], previousVersions[name])
+
+		for len(removed) > 0 && len(added) > 0 {
+			change := Change{Name: name, From: removed[0], To: added[0]}
+			if compareVersions(change.From, change.To) < 0 {
+				changes.Upgraded = append(changes.Upgraded, change)
+			} else {
+				changes.Downgraded = append(changes.Downgraded, change)
+			}
+			removed, added = removed[1:], added[1:]
+		}
+
+		for _, version := range removed {
+			changes.Removed = append(changes.Removed, Change{Name: name, From: version})
+		}
+
+		for _, version := range added {
+			changes.Added = append(changes.Added, Change{Name: name, To: version})
+		}
+	}
+
+	return changes
+}
+
+func packageVersions(l Lockfile) map[string]map[string]bool {
+	versions := map[string]map[string]bool{}
+	for _, pkg := range l.Packages {
+		if strings.Contains(pkg.Resolved, "@workspace:") {
+			continue
+		}
+
+		if versions[pkg.Name] == nil {
+			versions[pkg.Name] = map[string]bool{}
+		}
+		versions[pkg.Name][pkg.Version] = true
+	}
+
+	return versions
+}
+
+// subtract returns the versions in a that are not in b, in ascending order.
+func subtract(a, b map[string]bool) []string {
+	var versions []string
+	for version := range a {
+		if !b[version] {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+
+	return versions
+}
+
+// compareVersions compares two versions as semver.
+func compareVersions(a, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+
+	return va.Compare(vb)
+}
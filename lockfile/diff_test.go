@@ -0,0 +1,86 @@
+package lockfile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDiff(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	parse := func(content string) lockfile.Lockfile {
+		lf, err := lockfile.ParseClassic(strings.NewReader(content))
+		Expect(err).NotTo(HaveOccurred())
+		return lf
+	}
+
+	context("Diff", func() {
+		it("reports added, removed, upgraded and downgraded packages", func() {
+			previous := parse(`# yarn lockfile v1
+
+debug@^2.2.0:
+  version "2.6.9"
+
+express@^4.17.0:
+  version "4.17.3"
+
+leftpad@~0.0.1:
+  version "0.0.1"
+
+ms@2.1.0:
+  version "2.1.0"
+
+ms@2.0.0:
+  version "2.0.0"
+`)
+
+			current := parse(`# yarn lockfile v1
+
+debug@^2.2.0:
+  version "2.6.9"
+
+express@^4.18.0:
+  version "4.18.2"
+
+lodash@^4.17.21:
+  version "4.17.21"
+
+ms@2.0.0:
+  version "2.0.0"
+
+ms@^1.0.0:
+  version "1.0.0"
+`)
+
+			changes := lockfile.Diff(previous, current)
+			Expect(changes).To(Equal(lockfile.Changes{
+				Added:      []lockfile.Change{{Name: "lodash", To: "4.17.21"}},
+				Removed:    []lockfile.Change{{Name: "leftpad", From: "0.0.1"}},
+				Upgraded:   []lockfile.Change{{Name: "express", From: "4.17.3", To: "4.18.2"}},
+				Downgraded: []lockfile.Change{{Name: "ms", From: "2.1.0", To: "1.0.0"}},
+			}))
+			Expect(changes.Empty()).To(BeFalse())
+		})
+
+		it("compares versions as semver", func() {
+			changes := lockfile.Diff(
+				parse("# yarn lockfile v1\n\nleftpad@^1.0.0:\n  version \"1.9.0\"\n"),
+				parse("# yarn lockfile v1\n\nleftpad@^1.0.0:\n  version \"1.10.0\"\n"),
+			)
+			Expect(changes.Upgraded).To(Equal([]lockfile.Change{{Name: "leftpad", From: "1.9.0", To: "1.10.0"}}))
+		})
+
+		it("reports no changes for lockfiles that resolve the same versions", func() {
+			changes := lockfile.Diff(
+				parse("# yarn lockfile v1\n\nleftpad@~0.0.1:\n  version \"0.0.1\"\n"),
+				parse("# yarn lockfile v1\n\nleftpad@^0.0.1, leftpad@~0.0.1:\n  version \"0.0.1\"\n"),
+			)
+			Expect(changes.Empty()).To(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,17 @@
+package lockfile_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitLockfile(t *testing.T) {
+	suite := spec.New("lockfile", spec.Report(report.Terminal{}))
+	suite("Berry", testBerry)
+	suite("Classic", testClassic)
+	suite("Diff", testDiff)
+	suite("Lockfile", testLockfile)
+	suite.Run(t)
+}
@@ -0,0 +1,155 @@
+// Package lockfile parses yarn.lock files.
+package lockfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// FormatClassic identifies a Yarn Classic (v1) lockfile.
+	FormatClassic = "classic"
+
+	// FormatBerry identifies a Yarn Berry (v2+) lockfile.
+	FormatBerry = "berry"
+
+	// ClassicHeader is the comment that Yarn Classic writes at the top of
+	// every lockfile it generates.
+	ClassicHeader = "# yarn lockfile v1"
+)
+
+// Lockfile is the parsed representation of a yarn.lock file.
+type Lockfile struct {
+	Format   string
+	Metadata Metadata
+	Packages []Package
+
+	index map[string]int
+}
+
+// Package is a single resolved entry in a lockfile.
+type Package struct {
+	Name                 string
+	Version              string
+	Descriptors          []string
+	Resolved             string
+	Integrity            string
+	LinkType             string
+	Dependencies         map[string]string
+	OptionalDependencies map[string]string
+	DependenciesMeta     map[string]DependencyMeta
+}
+
+// Parse parses the lockfile at the given path.
+func Parse(path string) (Lockfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Lockfile{}, err
+	}
+
+	var lockfile Lockfile
+	switch DetectFormat(bytes.NewReader(content)) {
+	case FormatBerry:
+		lockfile, err = ParseBerry(bytes.NewReader(content))
+	default:
+		lockfile, err = ParseClassic(bytes.NewReader(content))
+	}
+	if err != nil {
+		return Lockfile{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return lockfile, nil
+}
+
+// DetectFormat reports whether a lockfile is Classic or Berry.
+func DetectFormat(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		switch {
+		case line == ClassicHeader:
+			return FormatClassic
+		case strings.HasPrefix(line, BerryMetadataKey+":"):
+			return FormatBerry
+		case line != "" && !strings.HasPrefix(line, "#"):
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// Lookup returns the package that satisfies the given descriptor.
+func (l Lockfile) Lookup(descriptor string) (Package, bool) {
+	i, ok := l.index[descriptor]
+	if !ok {
+		return Package{}, false
+	}
+
+	return l.Packages[i], true
+}
+
+// Dependencies returns the packages that the given package depends on.
+func (l Lockfile) Dependencies(pkg Package) []Package {
+	var dependencies []Package
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.OptionalDependencies} {
+		for name, rng := range deps {
+			dependency, ok := l.Lookup(Descriptor(name, rng))
+			if ok {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+
+	sort.Slice(dependencies, func(i, j int) bool {
+		if dependencies[i].Name == dependencies[j].Name {
+			return dependencies[i].Version < dependencies[j].Version
+		}
+		return dependencies[i].Name < dependencies[j].Name
+	})
+
+	return dependencies
+}
+
+// Descriptor joins a package name and a range into a lockfile descriptor.
+func Descriptor(name, rng string) string {
+	return fmt.Sprintf("%s@%s", name, rng)
+}
+
+// SplitDescriptor splits a descriptor into its name and range.
+func SplitDescriptor(descriptor string) (name, rng string) {
+	i := strings.Index(descriptor[min(1, len(descriptor)):], "@")
+	if i < 0 {
+		return descriptor, ""
+	}
+	i++
+
+	return descriptor[:i], descriptor[i+1:]
+}
+
+func (l *Lockfile) buildIndex() {
+	sort.Slice(l.Packages, func(i, j int) bool {
+		if l.Packages[i].Name != l.Packages[j].Name {
+			return l.Packages[i].Name < l.Packages[j].Name
+		}
+		if l.Packages[i].Version != l.Packages[j].Version {
+			return l.Packages[i].Version < l.Packages[j].Version
+		}
+		return l.Packages[i].Resolved < l.Packages[j].Resolved
+	})
+
+	l.index = make(map[string]int)
+	for i, pkg := range l.Packages {
+		for _, descriptor := range pkg.Descriptors {
+			l.index[descriptor] = i
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLockfile(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+body-parser@1.18.2:
+  version "1.18.2"
+  dependencies:
+    debug "2.6.9"
+    missing "^1.0.0"
+  optionalDependencies:
+    bytes "3.0.0"
+
+bytes@3.0.0:
+  version "3.0.0"
+
+debug@2.6.9, debug@^2.2.0:
+  version "2.6.9"
+  dependencies:
+    ms "2.0.0"
+
+ms@2.0.0:
+  version "2.0.0"
+`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("Parse", func() {
+		it("parses the lockfile at the given path", func() {
+			lf, err := lockfile.Parse(filepath.Join(workingDir, "yarn.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lf.Format).To(Equal(lockfile.FormatClassic))
+			Expect(lf.Packages).To(HaveLen(4))
+		})
+
+		context("when the lockfile was written by Yarn Berry", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(`__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"leftpad@npm:~0.0.1":
+  version: 0.0.1
+  resolution: "leftpad@npm:0.0.1"
+  languageName: node
+  linkType: hard
+`), 0600)).To(Succeed())
+			})
+
+			it("parses it as a Berry lockfile", func() {
+				lf, err := lockfile.Parse(filepath.Join(workingDir, "yarn.lock"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lf.Format).To(Equal(lockfile.FormatBerry))
+				Expect(lf.Packages).To(HaveLen(1))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the lockfile does not exist", func() {
+				it("returns an error", func() {
+					_, err := lockfile.Parse(filepath.Join(workingDir, "no-such-file"))
+					Expect(err).To(MatchError(os.ErrNotExist))
+				})
+			})
+
+			context("when the lockfile is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := lockfile.Parse(filepath.Join(workingDir, "yarn.lock"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+					Expect(err).To(MatchError(ContainSubstring("line 1: unexpected indentation")))
+				})
+			})
+		})
+	})
+
+	context("Lookup", func() {
+		it("finds packages by any of their descriptors", func() {
+			lf, err := lockfile.Parse(filepath.Join(workingDir, "yarn.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			pkg, ok := lf.Lookup("debug@^2.2.0")
+			Expect(ok).To(BeTrue())
+			Expect(pkg.Version).To(Equal("2.6.9"))
+
+			_, ok = lf.Lookup("debug@^3.0.0")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	context("Dependencies", func() {
+		it("returns the resolved dependencies of a package", func() {
+			lf, err := lockfile.Parse(filepath.Join(workingDir, "yarn.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			pkg, ok := lf.Lookup("body-parser@1.18.2")
+			Expect(ok).To(BeTrue())
+
+			var names []string
+			for _, dependency := range lf.Dependencies(pkg) {
+				names = append(names, dependency.Name)
+			}
+			Expect(names).To(Equal([]string{"bytes", "debug"}))
+		})
+	})
+
+	context("SplitDescriptor", func() {
+		it("splits scoped and unscoped descriptors", func() {
+			name, rng := lockfile.SplitDescriptor("@babel/core@^7.0.0")
+			Expect(name).To(Equal("@babel/core"))
+			Expect(rng).To(Equal("^7.0.0"))
+
+			name, rng = lockfile.SplitDescriptor("leftpad@~0.0.1")
+			Expect(name).To(Equal("leftpad"))
+			Expect(rng).To(Equal("~0.0.1"))
+
+			name, rng = lockfile.SplitDescriptor("leftpad")
+			Expect(name).To(Equal("leftpad"))
+			Expect(rng).To(Equal(""))
+		})
+	})
+}
@@ -0,0 +1,369 @@
+package yarninstall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anchore/syft/syft/file"
+	"github.com/anchore/syft/syft/pkg"
+	syftsbom "github.com/anchore/syft/syft/sbom"
+	"github.com/anchore/syft/syft/source"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// ModulesSBOMGenerator generates the SBOM of a modules layer from yarn.lock.
+type ModulesSBOMGenerator struct {
+	lockfiles map[string]lockfile.Lockfile
+}
+
+func NewModulesSBOMGenerator() ModulesSBOMGenerator {
+	return ModulesSBOMGenerator{
+		lockfiles: map[string]lockfile.Lockfile{},
+	}
+}
+
+// installedPackage is a package found in a modules layer.
+type installedPackage struct {
+	name     string
+	version  string
+	license  string
+	location string
+
+	// checksum is the SHA-512 of the archive of a Plug'n'Play package and
+	// unplugged marks the packages that Yarn extracted from their archive
+	checksum  string
+	unplugged bool
+}
+
+// Generate lists the packages installed in the given layer.
+func (g ModulesSBOMGenerator) Generate(projectPath, modulesLayerPath string) (LayerSBOM, error) {
+	lf, err := g.parseLockfile(projectPath)
+	if err != nil {
+		return LayerSBOM{}, err
+	}
+
+	entries := map[string]lockfile.Package{}
+	for _, entry := range lf.Packages {
+		entries[entry.Name+"@"+entry.Version] = entry
+	}
+
+	config, err := ParseYarnrcYml(projectPath)
+	if err != nil {
+		return LayerSBOM{}, fmt.Errorf("failed to parse %s: %w", YarnrcYml, err)
+	}
+
+	hasNodeModules, err := fs.Exists(filepath.Join(modulesLayerPath, "node_modules"))
+	if err != nil {
+		return LayerSBOM{}, err
+	}
+
+	var installed []installedPackage
+	if hasNodeModules {
+		installed, err = installedPackages(modulesLayerPath)
+		if err != nil {
+			return LayerSBOM{}, err
+		}
+	} else if lf.Format == lockfile.FormatBerry {
+		installed, err = pnpPackages(projectPath, modulesLayerPath, lf, config)
+		if err != nil {
+			return LayerSBOM{}, err
+		}
+	}
+
+	if len(installed) == 0 && !hasNodeModules {
+		for _, entry := range lf.Packages {
+			if strings.Contains(entry.Resolved, "@workspace:") {
+				continue
+			}
+
+			installed = append(installed, installedPackage{
+				name:     entry.Name,
+				version:  entry.Version,
+				location: "/" + YarnLock,
+			})
+		}
+	}
+
+	packages := map[string]*pkg.Package{}
+	archives := map[string]installedPackage{}
+	for _, p := range installed {
+		key := p.name + "@" + p.version
+
+		if p.checksum != "" || p.unplugged {
+			archive := archives[key]
+			archive.unplugged = archive.unplugged || p.unplugged
+			if p.checksum != "" {
+				archive.checksum = p.checksum
+			}
+			archives[key] = archive
+		}
+
+		if existing, ok := packages[key]; ok {
+			existing.Locations.Add(file.NewLocation(p.location))
+
+			// Only the unplugged copy of an archived package has a license
+			if p.license != "" && existing.Licenses.Empty() {
+				existing.Licenses.Add(pkg.NewLicenseWithContext(context.Background(), p.license))
+			}
+			continue
+		}
+
+		syftPackage := pkg.Package{
+			Name:      p.name,
+			Version:   p.version,
+			FoundBy:   "yarn-install",
+			Locations: file.NewLocationSet(file.NewLocation(p.location)),
+			Licenses:  pkg.NewLicenseSet(),
+			Language:  pkg.JavaScript,
+			Type:      pkg.NpmPkg,
+			PURL:      npmPURL(p.name, p.version),
+		}
+
+		if p.license != "" {
+			syftPackage.Licenses.Add(pkg.NewLicenseWithContext(context.Background(), p.license))
+		}
+
+		if entry, ok := entries[key]; ok {
+			syftPackage.Metadata = pkg.YarnLockEntry{
+				Resolved:  entry.Resolved,
+				Integrity: entry.Integrity,
+			}
+		}
+
+		packages[key] = &syftPackage
+	}
+
+	collection := pkg.NewCollection()
+	for _, p := range packages {
+		p.SetID()
+		collection.Add(*p)
+	}
+
+	manifests, err := projectManifests(projectPath)
+	if err != nil {
+		return LayerSBOM{}, err
+	}
+
+	registry := DefaultNpmRegistry
+	if config != nil && config.NpmRegistryServer != "" {
+		registry = config.NpmRegistryServer
+	}
+
+	installedKeys := map[string]bool{}
+	for key := range packages {
+		installedKeys[key] = true
+	}
+
+	bom := sbom.NewSBOM(syftsbom.SBOM{
+		Artifacts: syftsbom.Artifacts{
+			Packages: collection,
+		},
+		Source: source.Description{
+			Metadata: source.DirectoryMetadata{
+				Path: modulesLayerPath,
+			},
+		},
+	})
+
+	graph := buildDependencyGraph(manifests, lf, registry, installedKeys)
+	for key, archive := range archives {
+		p := packages[key]
+		graph.setArchive(npmPURL(p.Name, p.Version), archive.checksum, archive.unplugged)
+	}
+
+	return LayerSBOM{
+		SBOM:  bom,
+		graph: graph,
+	}, nil
+}
+
+func (g ModulesSBOMGenerator) parseLockfile(projectPath string) (lockfile.Lockfile, error) {
+	path := filepath.Join(projectPath, YarnLock)
+	if lf, ok := g.lockfiles[path]; ok {
+		return lf, nil
+	}
+
+	lf, err := lockfile.Parse(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return lockfile.Lockfile{}, err
+	}
+
+	if g.lockfiles != nil {
+		g.lockfiles[path] = lf
+	}
+
+	return lf, nil
+}
+
+// installedPackages returns the packages in a modules layer.
+func installedPackages(modulesLayerPath string) ([]installedPackage, error) {
+	roots := []string{filepath.Join(modulesLayerPath, "node_modules")}
+
+	err := filepath.WalkDir(filepath.Join(modulesLayerPath, "workspaces"), func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if entry.IsDir() && entry.Name() == "node_modules" {
+			roots = append(roots, path)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workspace node_modules: %w", err)
+	}
+
+	var packages []installedPackage
+	for _, root := range roots {
+		found, err := collectNodeModules(root, modulesLayerPath)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, found...)
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].location < packages[j].location
+	})
+
+	return packages, nil
+}
+
+func collectNodeModules(dir, modulesLayerPath string) ([]installedPackage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read node_modules directory: %w", err)
+	}
+
+	var packages []installedPackage
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// The pnpm linker of Yarn Berry keeps every package in the package
+		// directory of its entry in a store and links to it
+		if name == ".store" && entry.IsDir() {
+			store, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read pnpm store: %w", err)
+			}
+
+			for _, storeEntry := range store {
+				if storeEntry.IsDir() {
+					paths = append(paths, filepath.Join(dir, name, storeEntry.Name(), "package"))
+				}
+			}
+
+			continue
+		}
+
+		// Links point at workspaces, other local packages or the pnpm store
+		// and the other dot entries hold yarn metadata and the .bin links
+		if !entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if strings.HasPrefix(name, "@") {
+			scoped, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read scope directory: %w", err)
+			}
+
+			for _, scopedEntry := range scoped {
+				if scopedEntry.IsDir() {
+					paths = append(paths, filepath.Join(dir, name, scopedEntry.Name()))
+				}
+			}
+
+			continue
+		}
+
+		paths = append(paths, filepath.Join(dir, name))
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(filepath.Join(path, "package.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read package.json: %w", err)
+		}
+
+		var manifest struct {
+			Name    string          `json:"name"`
+			Version string          `json:"version"`
+			License json.RawMessage `json:"license"`
+		}
+		err = json.Unmarshal(content, &manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(path, "package.json"), err)
+		}
+
+		name := manifest.Name
+		if name == "" {
+			name, err = filepath.Rel(dir, path)
+			if err != nil {
+				return nil, err
+			}
+			name = filepath.ToSlash(name)
+		}
+
+		location, err := filepath.Rel(modulesLayerPath, filepath.Join(path, "package.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, installedPackage{
+			name:     name,
+			version:  manifest.Version,
+			license:  packageLicense(manifest.License),
+			location: "/" + filepath.ToSlash(location),
+		})
+
+		nested, err := collectNodeModules(filepath.Join(path, "node_modules"), modulesLayerPath)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, nested...)
+	}
+
+	return packages, nil
+}
+
+// packageLicense reads the license field of a package.json.
+func packageLicense(raw json.RawMessage) string {
+	var expression string
+	if err := json.Unmarshal(raw, &expression); err == nil {
+		return expression
+	}
+
+	var object struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &object); err == nil {
+		return object.Type
+	}
+
+	return ""
+}
+
+func npmPURL(name, version string) string {
+	return fmt.Sprintf("pkg:npm/%s@%s", strings.Replace(name, "@", "%40", 1), version)
+}
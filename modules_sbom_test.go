@@ -0,0 +1,514 @@
+package yarninstall_test
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testModulesSBOM(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectDir string
+		layerDir   string
+
+		generator yarninstall.ModulesSBOMGenerator
+	)
+
+	type artifact struct {
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		PURL     string `json:"purl"`
+		Licenses []struct {
+			Value string `json:"value"`
+		} `json:"licenses"`
+		Locations []struct {
+			Path string `json:"path"`
+		} `json:"locations"`
+		Metadata struct {
+			Resolved  string `json:"resolved"`
+			Integrity string `json:"integrity"`
+		} `json:"metadata"`
+	}
+
+	writePackage := func(path, manifest string) {
+		Expect(os.MkdirAll(path, os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(path, "package.json"), []byte(manifest), 0600)).To(Succeed())
+	}
+
+	artifacts := func() map[string]artifact {
+		bom, err := generator.Generate(projectDir, layerDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		formatter, err := bom.SBOM.InFormats("application/vnd.syft+json")
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := io.ReadAll(formatter.Formats()[0].Content)
+		Expect(err).NotTo(HaveOccurred())
+
+		var document struct {
+			Artifacts []artifact `json:"artifacts"`
+		}
+		Expect(json.Unmarshal(content, &document)).To(Succeed())
+
+		result := map[string]artifact{}
+		for _, a := range document.Artifacts {
+			result[a.Name+"@"+a.Version] = a
+		}
+
+		return result
+	}
+
+	formatted := func(mediaType string) map[string]interface{} {
+		bom, err := generator.Generate(projectDir, layerDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		persisted, err := yarninstall.PersistSBOM(bom, filepath.Join(layerDir, "sbom"), "some-key", []string{mediaType})
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := io.ReadAll(persisted.Formats()[0].Content)
+		Expect(err).NotTo(HaveOccurred())
+
+		var document map[string]interface{}
+		Expect(json.Unmarshal(content, &document)).To(Succeed())
+
+		return document
+	}
+
+	it.Before(func() {
+		var err error
+		projectDir, err = os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+
+		layerDir, err = os.MkdirTemp("", "layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+"@types/node@^20.0.0":
+  version "20.1.0"
+  resolved "https://registry.yarnpkg.com/@types/node/-/node-20.1.0.tgz#abc"
+  integrity sha512-dHlwZXM=
+
+debug@2.6.9:
+  version "2.6.9"
+  resolved "https://registry.yarnpkg.com/debug/-/debug-2.6.9.tgz#0123456789abcdef0123456789abcdef01234567"
+  integrity sha512-ZGVidWc=
+
+express@^4.18.0:
+  version "4.18.2"
+  resolved "https://registry.yarnpkg.com/express/-/express-4.18.2.tgz#ghi"
+  integrity sha512-ZXhwcmVzcw==
+  dependencies:
+    debug "2.6.9"
+`), 0600)).To(Succeed())
+
+		nodeModules := filepath.Join(layerDir, "node_modules")
+		writePackage(filepath.Join(nodeModules, "@types", "node"), `{"name": "@types/node", "version": "20.1.0", "license": "MIT"}`)
+		writePackage(filepath.Join(nodeModules, "express"), `{"name": "express", "version": "4.18.2", "license": {"type": "MIT"}}`)
+		writePackage(filepath.Join(nodeModules, "express", "node_modules", "debug"), `{"name": "debug", "version": "2.6.9"}`)
+		writePackage(filepath.Join(layerDir, "workspaces", "packages", "a", "node_modules", "debug"), `{"name": "debug", "version": "2.6.9"}`)
+
+		Expect(os.MkdirAll(filepath.Join(nodeModules, ".bin"), os.ModePerm)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(projectDir, "packages", "a"), filepath.Join(nodeModules, "a"))).To(Succeed())
+
+		generator = yarninstall.NewModulesSBOMGenerator()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(projectDir)).To(Succeed())
+		Expect(os.RemoveAll(layerDir)).To(Succeed())
+	})
+
+	context("Generate", func() {
+		it("lists the installed packages with their lockfile entries", func() {
+			result := artifacts()
+			Expect(result).To(HaveLen(3))
+
+			types := result["@types/node@20.1.0"]
+			Expect(types.PURL).To(Equal("pkg:npm/%40types/node@20.1.0"))
+			Expect(types.Licenses).To(HaveLen(1))
+			Expect(types.Licenses[0].Value).To(Equal("MIT"))
+			Expect(types.Metadata.Resolved).To(Equal("https://registry.yarnpkg.com/@types/node/-/node-20.1.0.tgz#abc"))
+			Expect(types.Metadata.Integrity).To(Equal("sha512-dHlwZXM="))
+
+			express := result["express@4.18.2"]
+			Expect(express.Licenses).To(HaveLen(1))
+			Expect(express.Licenses[0].Value).To(Equal("MIT"))
+
+			debug := result["debug@2.6.9"]
+			Expect(debug.Metadata.Integrity).To(Equal("sha512-ZGVidWc="))
+
+			var locations []string
+			for _, location := range debug.Locations {
+				locations = append(locations, location.Path)
+			}
+			Expect(locations).To(ConsistOf(
+				"/node_modules/express/node_modules/debug/package.json",
+				"/workspaces/packages/a/node_modules/debug/package.json",
+			))
+		})
+
+		context("when the layer was installed with the pnpm linker of Yarn Berry", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(layerDir, "node_modules"))).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(layerDir, "workspaces"))).To(Succeed())
+
+				store := filepath.Join(layerDir, "node_modules", ".store")
+				writePackage(filepath.Join(store, "express-npm-4.18.2-0a1b2c3d4e", "package"), `{"name": "express", "version": "4.18.2"}`)
+				writePackage(filepath.Join(store, "debug-npm-2.6.9-7d4cb597dc", "package"), `{"name": "debug", "version": "2.6.9"}`)
+				writePackage(filepath.Join(store, "@types-node-npm-20.1.0-c5561d67cd", "package"), `{"name": "@types/node", "version": "20.1.0"}`)
+
+				Expect(os.MkdirAll(filepath.Join(store, "express-npm-4.18.2-0a1b2c3d4e", "package", "node_modules"), os.ModePerm)).To(Succeed())
+				Expect(os.Symlink(filepath.Join(store, "debug-npm-2.6.9-7d4cb597dc", "package"), filepath.Join(store, "express-npm-4.18.2-0a1b2c3d4e", "package", "node_modules", "debug"))).To(Succeed())
+				Expect(os.Symlink(filepath.Join(store, "express-npm-4.18.2-0a1b2c3d4e", "package"), filepath.Join(layerDir, "node_modules", "express"))).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layerDir, "node_modules", "@types"), os.ModePerm)).To(Succeed())
+				Expect(os.Symlink(filepath.Join(store, "@types-node-npm-20.1.0-c5561d67cd", "package"), filepath.Join(layerDir, "node_modules", "@types", "node"))).To(Succeed())
+			})
+
+			it("lists the packages of the store once", func() {
+				result := artifacts()
+				Expect(result).To(HaveLen(3))
+
+				Expect(result["express@4.18.2"].Locations).To(HaveLen(1))
+				Expect(result["express@4.18.2"].Locations[0].Path).To(Equal("/node_modules/.store/express-npm-4.18.2-0a1b2c3d4e/package/package.json"))
+				Expect(result["debug@2.6.9"].Metadata.Integrity).To(Equal("sha512-ZGVidWc="))
+				Expect(result["@types/node@20.1.0"].Locations).To(HaveLen(1))
+			})
+		})
+
+		context("when the layer has no node_modules", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(layerDir, "node_modules"))).To(Succeed())
+			})
+
+			it("lists the packages of the lockfile", func() {
+				result := artifacts()
+				Expect(result).To(HaveLen(3))
+				Expect(result["express@4.18.2"].Metadata.Resolved).To(Equal("https://registry.yarnpkg.com/express/-/express-4.18.2.tgz#ghi"))
+			})
+		})
+
+		context("when the layer holds a Plug'n'Play install", func() {
+			var expressChecksum, esbuildChecksum string
+
+			checksum := func(content string) string {
+				sum := sha512.Sum512([]byte(content))
+				return hex.EncodeToString(sum[:])
+			}
+
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(layerDir, "node_modules"))).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(layerDir, "workspaces"))).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"@types/node@npm:^20.0.0":
+  version: 20.1.0
+  resolution: "@types/node@npm:20.1.0"
+  checksum: 10c0/7c8d9e0f1a2b
+  languageName: node
+  linkType: hard
+
+"esbuild@npm:^0.19.0":
+  version: 0.19.0
+  resolution: "esbuild@npm:0.19.0"
+  checksum: 10c0/e5b0c1d2f3a4
+  languageName: node
+  linkType: hard
+
+"express@npm:^4.18.0":
+  version: 4.18.2
+  resolution: "express@npm:4.18.2"
+  checksum: 10c0/3fabe08296ab
+  languageName: node
+  linkType: hard
+`), 0600)).To(Succeed())
+
+				cache := filepath.Join(layerDir, "cache")
+				Expect(os.MkdirAll(cache, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(cache, "express-npm-4.18.2-bb15ff679a-3fabe08296.zip"), []byte("express archive"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(cache, "esbuild-npm-0.19.0-1a8543a0f4-e5b0c1d2f3.zip"), []byte("esbuild archive"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(cache, "express-npm-4.17.1-6815ee6bf9-0000000000.zip"), []byte("stale archive"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(cache, "express-npm-4.18.2-bb15ff679a-0000000000.zip"), []byte("stale archive"), 0600)).To(Succeed())
+
+				writePackage(filepath.Join(layerDir, "pnp", ".yarn", "unplugged", "esbuild-npm-0.19.0-1a8543a0f4", "node_modules", "esbuild"), `{"name": "esbuild", "version": "0.19.0", "license": "MIT"}`)
+
+				expressChecksum = checksum("express archive")
+				esbuildChecksum = checksum("esbuild archive")
+			})
+
+			it("lists the packages that have an archive in the cache", func() {
+				result := artifacts()
+				Expect(result).To(HaveLen(2))
+
+				var locations []string
+				for _, location := range result["express@4.18.2"].Locations {
+					locations = append(locations, location.Path)
+				}
+				Expect(locations).To(ConsistOf("/cache/express-npm-4.18.2-bb15ff679a-3fabe08296.zip"))
+
+				locations = nil
+				for _, location := range result["esbuild@0.19.0"].Locations {
+					locations = append(locations, location.Path)
+				}
+				Expect(locations).To(ConsistOf(
+					"/cache/esbuild-npm-0.19.0-1a8543a0f4-e5b0c1d2f3.zip",
+					"/pnp/.yarn/unplugged/esbuild-npm-0.19.0-1a8543a0f4/node_modules/esbuild/package.json",
+				))
+				Expect(result["esbuild@0.19.0"].Licenses[0].Value).To(Equal("MIT"))
+			})
+
+			it("records the checksum of every archive and marks unplugged packages", func() {
+				document := formatted(sbom.CycloneDXFormat)
+
+				components := map[string]map[string]interface{}{}
+				for _, c := range document["components"].([]interface{}) {
+					component := c.(map[string]interface{})
+					components[component["name"].(string)] = component
+				}
+
+				Expect(components["express"]).To(HaveKeyWithValue("hashes", []interface{}{
+					map[string]interface{}{"alg": "SHA-512", "content": expressChecksum},
+				}))
+				Expect(components["express"]["properties"]).NotTo(ContainElement(HaveKeyWithValue("name", "yarn:unplugged")))
+
+				Expect(components["esbuild"]).To(HaveKeyWithValue("hashes", []interface{}{
+					map[string]interface{}{"alg": "SHA-512", "content": esbuildChecksum},
+				}))
+				Expect(components["esbuild"]["properties"]).To(ContainElement(map[string]interface{}{"name": "yarn:unplugged", "value": "true"}))
+
+				document = formatted(sbom.SPDXFormat)
+
+				packages := map[string]map[string]interface{}{}
+				for _, p := range document["packages"].([]interface{}) {
+					spdxPackage := p.(map[string]interface{})
+					packages[spdxPackage["name"].(string)] = spdxPackage
+				}
+
+				Expect(packages["esbuild"]).To(HaveKeyWithValue("checksums", []interface{}{
+					map[string]interface{}{"algorithm": "SHA512", "checksumValue": esbuildChecksum},
+				}))
+				Expect(packages["esbuild"]).To(HaveKeyWithValue("comment", "Unplugged by Yarn"))
+				Expect(packages["express"]).NotTo(HaveKey("comment"))
+			})
+
+			context("when the archives are named after the cache key", func() {
+				it.Before(func() {
+					cache := filepath.Join(layerDir, "cache")
+					Expect(os.WriteFile(filepath.Join(cache, "@types-node-npm-20.1.0-c5561d67cd-10c0.zip"), []byte("types archive"), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(cache, "@types-node-npm-20.1.0-c5561d67cd-9c0.zip"), []byte("stale archive"), 0600)).To(Succeed())
+				})
+
+				it("lists them", func() {
+					result := artifacts()
+					Expect(result).To(HaveLen(3))
+
+					var locations []string
+					for _, location := range result["@types/node@20.1.0"].Locations {
+						locations = append(locations, location.Path)
+					}
+					Expect(locations).To(ConsistOf("/cache/@types-node-npm-20.1.0-c5561d67cd-10c0.zip"))
+				})
+			})
+
+			context("when the project brings its own cache", func() {
+				it.Before(func() {
+					Expect(os.RemoveAll(filepath.Join(layerDir, "cache"))).To(Succeed())
+					Expect(os.RemoveAll(filepath.Join(layerDir, "pnp"))).To(Succeed())
+
+					cache := filepath.Join(projectDir, ".yarn", "cache")
+					Expect(os.MkdirAll(cache, os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(cache, "express-npm-4.18.2-bb15ff679a-3fabe08296.zip"), []byte("express archive"), 0600)).To(Succeed())
+
+					writePackage(filepath.Join(projectDir, ".yarn", "unplugged", "esbuild-npm-0.19.0-1a8543a0f4", "node_modules", "esbuild"), `{"name": "esbuild", "version": "0.19.0"}`)
+				})
+
+				it("lists the archives and unplugged packages of the project", func() {
+					result := artifacts()
+					Expect(result).To(HaveLen(2))
+					Expect(result["express@4.18.2"].Locations[0].Path).To(Equal(filepath.Join(projectDir, ".yarn", "cache", "express-npm-4.18.2-bb15ff679a-3fabe08296.zip")))
+					Expect(result["esbuild@0.19.0"].Locations[0].Path).To(Equal(filepath.Join(projectDir, ".yarn", "unplugged", "esbuild-npm-0.19.0-1a8543a0f4", "node_modules", "esbuild", "package.json")))
+				})
+			})
+
+			context("when the cache holds no archive of the lockfile", func() {
+				it.Before(func() {
+					Expect(os.RemoveAll(filepath.Join(layerDir, "cache"))).To(Succeed())
+					Expect(os.RemoveAll(filepath.Join(layerDir, "pnp"))).To(Succeed())
+				})
+
+				it("lists the packages of the lockfile", func() {
+					Expect(artifacts()).To(HaveLen(3))
+				})
+			})
+		})
+
+		context("when the SBOM is formatted", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{
+					"dependencies": {
+						"express": "^4.18.0"
+					},
+					"devDependencies": {
+						"@types/node": "^20.0.0"
+					}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("adds scopes, hashes, URLs and the dependency graph to CycloneDX", func() {
+				document := formatted(sbom.CycloneDXFormat)
+
+				refs := map[string]string{}
+				components := map[string]map[string]interface{}{}
+				for _, c := range document["components"].([]interface{}) {
+					component := c.(map[string]interface{})
+					components[component["name"].(string)] = component
+					refs[component["name"].(string)] = component["bom-ref"].(string)
+				}
+
+				Expect(components["express"]).To(HaveKeyWithValue("scope", "required"))
+				Expect(components["debug"]).To(HaveKeyWithValue("scope", "required"))
+				Expect(components["@types/node"]).To(HaveKeyWithValue("scope", "excluded"))
+
+				Expect(components["express"]).To(HaveKeyWithValue("hashes", []interface{}{
+					map[string]interface{}{"alg": "SHA-512", "content": "65787072657373"},
+				}))
+				Expect(components["debug"]).To(HaveKeyWithValue("hashes", []interface{}{
+					map[string]interface{}{"alg": "SHA-512", "content": "6465627567"},
+					map[string]interface{}{"alg": "SHA-1", "content": "0123456789abcdef0123456789abcdef01234567"},
+				}))
+				Expect(components["express"]).To(HaveKeyWithValue("externalReferences", []interface{}{
+					map[string]interface{}{"type": "distribution", "url": "https://registry.yarnpkg.com/express/-/express-4.18.2.tgz"},
+				}))
+
+				root := document["metadata"].(map[string]interface{})["component"].(map[string]interface{})["bom-ref"].(string)
+				Expect(document["dependencies"]).To(ConsistOf(
+					map[string]interface{}{"ref": root, "dependsOn": []interface{}{refs["@types/node"], refs["express"]}},
+					map[string]interface{}{"ref": refs["express"], "dependsOn": []interface{}{refs["debug"]}},
+					map[string]interface{}{"ref": refs["debug"], "dependsOn": []interface{}{}},
+					map[string]interface{}{"ref": refs["@types/node"], "dependsOn": []interface{}{}},
+				))
+			})
+
+			it("adds checksums, download locations and dependency relationships to SPDX", func() {
+				document := formatted(sbom.SPDXFormat)
+
+				ids := map[string]string{}
+				packages := map[string]map[string]interface{}{}
+				for _, p := range document["packages"].([]interface{}) {
+					spdxPackage := p.(map[string]interface{})
+					packages[spdxPackage["name"].(string)] = spdxPackage
+					ids[spdxPackage["name"].(string)] = spdxPackage["SPDXID"].(string)
+				}
+
+				Expect(packages["express"]).To(HaveKeyWithValue("checksums", []interface{}{
+					map[string]interface{}{"algorithm": "SHA512", "checksumValue": "65787072657373"},
+				}))
+				Expect(packages["express"]).To(HaveKeyWithValue("downloadLocation", "https://registry.yarnpkg.com/express/-/express-4.18.2.tgz"))
+
+				var root string
+				for _, r := range document["relationships"].([]interface{}) {
+					relationship := r.(map[string]interface{})
+					if relationship["relationshipType"] == "DESCRIBES" {
+						root = relationship["relatedSpdxElement"].(string)
+					}
+				}
+
+				Expect(document["relationships"]).To(ContainElements(
+					map[string]interface{}{"spdxElementId": root, "relationshipType": "DEPENDS_ON", "relatedSpdxElement": ids["express"]},
+					map[string]interface{}{"spdxElementId": ids["express"], "relationshipType": "DEPENDS_ON", "relatedSpdxElement": ids["debug"]},
+					map[string]interface{}{"spdxElementId": ids["@types/node"], "relationshipType": "DEV_DEPENDENCY_OF", "relatedSpdxElement": root},
+				))
+			})
+
+			context("when the lockfile was written by Yarn Berry", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"@types/node@npm:^20.0.0":
+  version: 20.1.0
+  resolution: "@types/node@npm:20.1.0"
+  checksum: 10c0/abcdef
+  languageName: node
+  linkType: hard
+
+"express@npm:^4.18.0":
+  version: 4.18.2
+  resolution: "express@npm:4.18.2"
+  checksum: 10c0/012345
+  languageName: node
+  linkType: hard
+`), 0600)).To(Succeed())
+
+					Expect(os.WriteFile(filepath.Join(projectDir, ".yarnrc.yml"), []byte("npmRegistryServer: https://registry.example.com/\n"), 0600)).To(Succeed())
+				})
+
+				it("derives the URLs from the registry", func() {
+					document := formatted(sbom.CycloneDXFormat)
+
+					components := map[string]map[string]interface{}{}
+					for _, c := range document["components"].([]interface{}) {
+						component := c.(map[string]interface{})
+						components[component["name"].(string)] = component
+					}
+
+					Expect(components["@types/node"]).To(HaveKeyWithValue("externalReferences", []interface{}{
+						map[string]interface{}{"type": "distribution", "url": "https://registry.example.com/@types/node/-/node-20.1.0.tgz"},
+					}))
+					Expect(components["@types/node"]).To(HaveKeyWithValue("scope", "excluded"))
+					Expect(components["express"]).NotTo(HaveKey("hashes"))
+				})
+			})
+		})
+
+		context("when the SBOMs of several layers are generated", func() {
+			it("parses the yarn.lock once", func() {
+				Expect(artifacts()["express@4.18.2"].Metadata.Integrity).To(Equal("sha512-ZXhwcmVzcw=="))
+
+				Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+
+				Expect(artifacts()["express@4.18.2"].Metadata.Integrity).To(Equal("sha512-ZXhwcmVzcw=="))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the yarn.lock cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := generator.Generate(projectDir, layerDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+
+			context("when an installed package.json is malformed", func() {
+				it.Before(func() {
+					writePackage(filepath.Join(layerDir, "node_modules", "express"), "%%%")
+				})
+
+				it("returns an error", func() {
+					_, err := generator.Generate(projectDir, layerDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+		})
+	})
+}
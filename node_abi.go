@@ -0,0 +1,69 @@
+package yarninstall
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NodeABI returns the NODE_MODULE_VERSION of the node in NODE_HOME.
+func NodeABI() (string, error) {
+	defines, err := nodeVersionDefines()
+	if err != nil {
+		return "", err
+	}
+
+	return defines["NODE_MODULE_VERSION"], nil
+}
+
+// NodeVersion returns the version of the node in NODE_HOME.
+func NodeVersion() (string, error) {
+	defines, err := nodeVersionDefines()
+	if err != nil {
+		return "", err
+	}
+
+	major, minor, patch := defines["NODE_MAJOR_VERSION"], defines["NODE_MINOR_VERSION"], defines["NODE_PATCH_VERSION"]
+	if major == "" || minor == "" || patch == "" {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%s.%s.%s", major, minor, patch), nil
+}
+
+// nodeVersionDefines returns the defines of node_version.h.
+func nodeVersionDefines() (map[string]string, error) {
+	defines := map[string]string{}
+
+	nodeHome := os.Getenv("NODE_HOME")
+	if nodeHome == "" {
+		return defines, nil
+	}
+
+	file, err := os.Open(filepath.Join(nodeHome, "include", "node", "node_version.h"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return defines, nil
+		}
+		return nil, fmt.Errorf("failed to open node_version.h: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "#define" && strings.HasPrefix(fields[1], "NODE_") {
+			defines[fields[1]] = fields[2]
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node_version.h: %w", err)
+	}
+
+	return defines, nil
+}
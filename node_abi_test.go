@@ -0,0 +1,85 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testNodeABI(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		nodeHome string
+	)
+
+	it.Before(func() {
+		var err error
+		nodeHome, err = os.MkdirTemp("", "node-home")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(nodeHome, "include", "node"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(nodeHome, "include", "node", "node_version.h"), []byte(`#ifndef SRC_NODE_VERSION_H_
+#define SRC_NODE_VERSION_H_
+
+#define NODE_MAJOR_VERSION 20
+#define NODE_MINOR_VERSION 11
+#define NODE_PATCH_VERSION 1
+
+#define NODE_MODULE_VERSION 115
+
+#endif
+`), 0600)).To(Succeed())
+
+		t.Setenv("NODE_HOME", nodeHome)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(nodeHome)).To(Succeed())
+	})
+
+	it("returns the NODE_MODULE_VERSION of the node installation", func() {
+		abi, err := yarninstall.NodeABI()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(abi).To(Equal("115"))
+	})
+
+	it("returns the version of the node installation", func() {
+		version, err := yarninstall.NodeVersion()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal("20.11.1"))
+	})
+
+	context("when the headers are not installed", func() {
+		it.Before(func() {
+			Expect(os.RemoveAll(filepath.Join(nodeHome, "include"))).To(Succeed())
+		})
+
+		it("returns an empty string", func() {
+			abi, err := yarninstall.NodeABI()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(abi).To(Equal(""))
+
+			version, err := yarninstall.NodeVersion()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(""))
+		})
+	})
+
+	context("when NODE_HOME is not set", func() {
+		it.Before(func() {
+			t.Setenv("NODE_HOME", "")
+		})
+
+		it("returns an empty string", func() {
+			abi, err := yarninstall.NodeABI()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(abi).To(Equal(""))
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package yarninstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// VerifyNodeModules checks that the direct dependencies are installed at
+// the versions yarn.lock resolves.
+func VerifyNodeModules(projectPath, nodeModulesPath string, production bool) error {
+	manifest, err := ParsePackageManifest(projectPath)
+	if err != nil {
+		return err
+	}
+
+	lf, err := lockfile.Parse(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil {
+		return err
+	}
+
+	dependencies := map[string]string{}
+	for name, rng := range manifest.Dependencies {
+		dependencies[name] = rng
+	}
+
+	if !production {
+		for name, rng := range manifest.DevDependencies {
+			dependencies[name] = rng
+		}
+	}
+
+	var names []string
+	for name := range dependencies {
+		if _, ok := manifest.OptionalDependencies[name]; ok {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		installed, err := installedVersion(filepath.Join(nodeModulesPath, filepath.FromSlash(name)))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("dependency %s is not installed", name)
+			}
+			return fmt.Errorf("failed to read installed version of %s: %w", name, err)
+		}
+
+		pkg, ok := lookupDependency(lf, name, dependencies[name])
+		if !ok || pkg.LinkType == "soft" || strings.HasSuffix(pkg.Version, "-use.local") {
+			continue
+		}
+
+		if installed != pkg.Version {
+			return fmt.Errorf("dependency %s is installed at version %s but yarn.lock resolves it to %s", name, installed, pkg.Version)
+		}
+	}
+
+	return nil
+}
+
+// lookupDependency finds the lockfile entry of a package.json dependency.
+func lookupDependency(lf lockfile.Lockfile, name, rng string) (lockfile.Package, bool) {
+	pkg, ok := lf.Lookup(lockfile.Descriptor(name, rng))
+	if ok || lf.Format != lockfile.FormatBerry || strings.Contains(rng, ":") {
+		return pkg, ok
+	}
+
+	return lf.Lookup(lockfile.Descriptor(name, "npm:"+rng))
+}
+
+func installedVersion(packagePath string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(packagePath, "package.json"))
+	if err != nil {
+		return "", err
+	}
+
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	err = json.Unmarshal(content, &pkg)
+	if err != nil {
+		return "", err
+	}
+
+	return pkg.Version, nil
+}
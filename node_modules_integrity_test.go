@@ -0,0 +1,145 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testNodeModulesIntegrity(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectDir      string
+		nodeModulesPath string
+	)
+
+	writePackage := func(name, version string) {
+		Expect(os.MkdirAll(filepath.Join(nodeModulesPath, name), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(nodeModulesPath, name, "package.json"), []byte(`{"name": "`+name+`", "version": "`+version+`"}`), 0600)).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		projectDir, err = os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+
+		nodeModulesPath = filepath.Join(projectDir, "node_modules")
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{
+			"dependencies": {
+				"leftpad": "~0.0.1",
+				"fsevents": "^2.3.2"
+			},
+			"devDependencies": {
+				"debug": "^2.2.0"
+			},
+			"optionalDependencies": {
+				"fsevents": "^2.3.2"
+			}
+		}`), 0600)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+debug@^2.2.0:
+  version "2.6.9"
+
+leftpad@~0.0.1:
+  version "0.0.1"
+`), 0600)).To(Succeed())
+
+		writePackage("leftpad", "0.0.1")
+		writePackage("debug", "2.6.9")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(projectDir)).To(Succeed())
+	})
+
+	context("VerifyNodeModules", func() {
+		it("accepts a tree that matches yarn.lock", func() {
+			Expect(yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, false)).To(Succeed())
+		})
+
+		context("when a dependency is installed at another version", func() {
+			it.Before(func() {
+				writePackage("debug", "2.6.8")
+			})
+
+			it("returns an error", func() {
+				err := yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, false)
+				Expect(err).To(MatchError("dependency debug is installed at version 2.6.8 but yarn.lock resolves it to 2.6.9"))
+			})
+
+			it("ignores it when it is a devDependency of a production install", func() {
+				Expect(yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, true)).To(Succeed())
+			})
+		})
+
+		context("when a dependency is missing", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(nodeModulesPath, "leftpad"))).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, true)
+				Expect(err).To(MatchError("dependency leftpad is not installed"))
+			})
+		})
+
+		context("when the lockfile was written by Yarn Berry", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"debug@npm:^2.2.0":
+  version: 2.6.9
+  resolution: "debug@npm:2.6.9"
+  languageName: node
+  linkType: hard
+
+"leftpad@npm:~0.0.1":
+  version: 0.0.2
+  resolution: "leftpad@npm:0.0.2"
+  languageName: node
+  linkType: hard
+`), 0600)).To(Succeed())
+			})
+
+			it("resolves the dependencies through the npm protocol", func() {
+				err := yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, false)
+				Expect(err).To(MatchError("dependency leftpad is installed at version 0.0.1 but yarn.lock resolves it to 0.0.2"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the yarn.lock cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, false)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+
+			context("when an installed package.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(nodeModulesPath, "leftpad", "package.json"), []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := yarninstall.VerifyNodeModules(projectDir, nodeModulesPath, false)
+					Expect(err).To(MatchError(ContainSubstring("failed to read installed version of leftpad")))
+				})
+			})
+		})
+	})
+}
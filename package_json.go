@@ -0,0 +1,118 @@
+package yarninstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PackageManifest holds the package.json fields the buildpack reads.
+type PackageManifest struct {
+	PackageManager string `json:"packageManager"`
+	Type           string `json:"type"`
+	Engines        struct {
+		Yarn string `json:"yarn"`
+	} `json:"engines"`
+	Workspaces           Workspaces        `json:"workspaces"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+// Workspaces holds the workspace patterns of a package.json file.
+type Workspaces []string
+
+func (w *Workspaces) UnmarshalJSON(data []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	*w = object.Packages
+	return nil
+}
+
+// ParsePackageManifest parses the package.json in the given project path.
+func ParsePackageManifest(projectPath string) (PackageManifest, error) {
+	content, err := os.ReadFile(filepath.Join(projectPath, "package.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return PackageManifest{}, nil
+		}
+		return PackageManifest{}, err
+	}
+
+	var manifest PackageManifest
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return PackageManifest{}, fmt.Errorf("unable to decode package.json: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// YarnVersion returns the Yarn version pinned by packageManager.
+func (m PackageManifest) YarnVersion() string {
+	name, version, found := strings.Cut(m.PackageManager, "@")
+	if !found || name != "yarn" {
+		return ""
+	}
+
+	version, _, _ = strings.Cut(version, "+")
+
+	if _, err := semver.NewVersion(version); err != nil {
+		return ""
+	}
+
+	return version
+}
+
+// WorkspaceDirs returns the workspace directories of the project.
+func (m PackageManifest) WorkspaceDirs(projectPath string) ([]string, error) {
+	found := map[string]bool{}
+	var dirs []string
+	for _, pattern := range m.Workspaces {
+		matches, err := filepath.Glob(filepath.Join(projectPath, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			rel, err := filepath.Rel(projectPath, match)
+			if err != nil {
+				return nil, err
+			}
+
+			if rel == "." || strings.HasPrefix(rel, "..") || found[rel] {
+				continue
+			}
+
+			info, err := os.Stat(filepath.Join(match, "package.json"))
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			found[rel] = true
+			dirs = append(dirs, rel)
+		}
+	}
+
+	sort.Strings(dirs)
+
+	return dirs, nil
+}
@@ -0,0 +1,136 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPackageJSON(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectPath string
+	)
+
+	it.Before(func() {
+		var err error
+		projectPath, err = os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(projectPath)).To(Succeed())
+	})
+
+	context("ParsePackageManifest", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(projectPath, "package.json"), []byte(`{
+				"packageManager": "yarn@3.6.0+sha224.953c8233f7a92884eee2de69a1b92d1f2ec1655e66d08071ba9a02fa",
+				"engines": {
+					"yarn": ">=3"
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("parses the package.json file", func() {
+			manifest, err := yarninstall.ParsePackageManifest(projectPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.PackageManager).To(Equal("yarn@3.6.0+sha224.953c8233f7a92884eee2de69a1b92d1f2ec1655e66d08071ba9a02fa"))
+			Expect(manifest.YarnVersion()).To(Equal("3.6.0"))
+			Expect(manifest.Engines.Yarn).To(Equal(">=3"))
+		})
+
+		context("when there is no package.json file", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(projectPath, "package.json"))).To(Succeed())
+			})
+
+			it("returns an empty manifest", func() {
+				manifest, err := yarninstall.ParsePackageManifest(projectPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest).To(Equal(yarninstall.PackageManifest{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the package.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(projectPath, "package.json"), []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.ParsePackageManifest(projectPath)
+					Expect(err).To(MatchError(ContainSubstring("unable to decode package.json")))
+				})
+			})
+		})
+	})
+
+	context("YarnVersion", func() {
+		it("returns an empty string when the package manager is not yarn", func() {
+			Expect(yarninstall.PackageManifest{PackageManager: "pnpm@8.6.0"}.YarnVersion()).To(Equal(""))
+			Expect(yarninstall.PackageManifest{}.YarnVersion()).To(Equal(""))
+		})
+
+		it("returns versions without a hash", func() {
+			Expect(yarninstall.PackageManifest{PackageManager: "yarn@1.22.19"}.YarnVersion()).To(Equal("1.22.19"))
+		})
+
+		it("returns an empty string when the release is given by URL", func() {
+			Expect(yarninstall.PackageManifest{PackageManager: "yarn@https://repo.yarnpkg.com/4.0.2/packages/yarnpkg-cli/bin/yarn.js"}.YarnVersion()).To(Equal(""))
+		})
+	})
+
+	context("WorkspaceDirs", func() {
+		it.Before(func() {
+			for _, dir := range []string{"packages/a", "packages/b", "packages/not-a-workspace", "tools/c"} {
+				Expect(os.MkdirAll(filepath.Join(projectPath, dir), os.ModePerm)).To(Succeed())
+			}
+
+			for _, dir := range []string{"packages/a", "packages/b", "tools/c"} {
+				Expect(os.WriteFile(filepath.Join(projectPath, dir, "package.json"), []byte("{}"), 0600)).To(Succeed())
+			}
+		})
+
+		it("expands the workspace patterns", func() {
+			Expect(os.WriteFile(filepath.Join(projectPath, "package.json"), []byte(`{
+				"workspaces": ["packages/*", "tools/c", "packages/a"]
+			}`), 0600)).To(Succeed())
+
+			manifest, err := yarninstall.ParsePackageManifest(projectPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			dirs, err := manifest.WorkspaceDirs(projectPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dirs).To(Equal([]string{"packages/a", "packages/b", "tools/c"}))
+		})
+
+		it("accepts the object form of the workspaces field", func() {
+			Expect(os.WriteFile(filepath.Join(projectPath, "package.json"), []byte(`{
+				"workspaces": {"packages": ["tools/*"]}
+			}`), 0600)).To(Succeed())
+
+			manifest, err := yarninstall.ParsePackageManifest(projectPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			dirs, err := manifest.WorkspaceDirs(projectPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dirs).To(Equal([]string{"tools/c"}))
+		})
+
+		context("failure cases", func() {
+			context("when a pattern is malformed", func() {
+				it("returns an error", func() {
+					_, err := yarninstall.PackageManifest{Workspaces: []string{"packages/["}}.WorkspaceDirs(projectPath)
+					Expect(err).To(MatchError(ContainSubstring(`invalid workspace pattern "packages/["`)))
+				})
+			})
+		})
+	})
+}
@@ -25,25 +25,78 @@ func NewPackageManagerConfigurationManager(bindingResolver BindingResolver, logs
 	}
 }
 
-func (p PackageManagerConfigurationManager) DeterminePath(typ, platformDir, entry string) (string, error) {
+// DeterminePath returns the path to the given entry of a binding.
+func (p PackageManagerConfigurationManager) DeterminePath(typ, platformDir, entry string) (string, func() error, error) {
 	bindings, err := p.bindingResolver.Resolve(typ, "", platformDir)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if len(bindings) > 1 {
-		return "", fmt.Errorf("failed: binding resolver found more than one binding of type '%s'", typ)
+		p.logs.Process("Loading service bindings of type '%s'", typ)
+	} else if len(bindings) == 1 {
+		p.logs.Process("Loading service binding of type '%s'", typ)
 	}
 
-	if len(bindings) == 1 {
-		p.logs.Process("Loading service binding of type '%s'", typ)
+	for _, binding := range bindings {
+		if _, ok := binding.Entries[entry]; !ok {
+			return "", nil, fmt.Errorf("failed: binding of type '%s' does not contain required entry '%s'", typ, entry)
+		}
+	}
+
+	var registries []servicebindings.Binding
+	switch entry {
+	case ".npmrc", ".yarnrc", YarnrcYml:
+		registries, err = p.bindingResolver.Resolve(NpmRegistryBindingType, "", platformDir)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(registries) > 0 {
+		p.logs.Process("Rendering service bindings of type '%s' as %s", NpmRegistryBindingType, entry)
+	}
+
+	for _, binding := range registries {
+		registry, err := parseNpmRegistry(binding)
+		if err != nil {
+			return "", nil, err
+		}
 
-		if _, ok := bindings[0].Entries[entry]; !ok {
-			return "", fmt.Errorf("failed: binding of type '%s' does not contain required entry '%s'", typ, entry)
+		content, err := registry.render(entry)
+		if err != nil {
+			return "", nil, err
 		}
 
-		return filepath.Join(bindings[0].Path, entry), nil
+		bindings = append(bindings, servicebindings.Binding{
+			Name:    binding.Name,
+			Type:    typ,
+			Path:    binding.Path,
+			Entries: map[string]*servicebindings.Entry{entry: servicebindings.NewWithValue(content)},
+		})
 	}
 
-	return "", nil
+	if len(bindings) == 0 {
+		return "", noCleanup, nil
+	}
+
+	if len(bindings) == 1 && len(registries) == 0 {
+		p.logs.Subprocess("Using binding '%s'", bindings[0].Name)
+		return filepath.Join(bindings[0].Path, entry), noCleanup, nil
+	}
+
+	path, cleanup, err := mergeBindings(typ, entry, bindings)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, binding := range bindings {
+		p.logs.Subprocess("Merged binding '%s'", binding.Name)
+	}
+
+	return path, cleanup, nil
+}
+
+func noCleanup() error {
+	return nil
 }
@@ -3,6 +3,7 @@ package yarninstall_test
 import (
 	"bytes"
 	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -13,14 +14,16 @@ import (
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
+	. "github.com/paketo-buildpacks/occam/matchers"
 )
 
 func testPackageManagerConfigurationManager(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect = NewWithT(t).Expect
 
-		buffer          *bytes.Buffer
-		bindingResolver *fakes.BindingResolver
+		buffer           *bytes.Buffer
+		bindingResolver  *fakes.BindingResolver
+		registryBindings []servicebindings.Binding
 
 		packageManagerConfigurationManager yarninstall.PackageManagerConfigurationManager
 	)
@@ -28,6 +31,14 @@ func testPackageManagerConfigurationManager(t *testing.T, context spec.G, it spe
 	it.Before(func() {
 		bindingResolver = &fakes.BindingResolver{}
 
+		registryBindings = nil
+		bindingResolver.ResolveCall.Stub = func(typ, provider, platformDir string) ([]servicebindings.Binding, error) {
+			if typ == "npm-registry" {
+				return registryBindings, nil
+			}
+			return bindingResolver.ResolveCall.Returns.BindingSlice, bindingResolver.ResolveCall.Returns.Error
+		}
+
 		buffer = bytes.NewBuffer(nil)
 
 		packageManagerConfigurationManager = yarninstall.NewPackageManagerConfigurationManager(bindingResolver, scribe.NewEmitter(buffer))
@@ -48,30 +59,417 @@ func testPackageManagerConfigurationManager(t *testing.T, context spec.G, it spe
 				}
 			})
 			it("returns a path to the configuration file", func() {
-				path, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(path).To(Equal(filepath.Join("some-binding-path", "some-entry")))
+				Expect(cleanup()).To(Succeed())
 
 				Expect(bindingResolver.ResolveCall.Receives.Typ).To(Equal("some-typ"))
 				Expect(bindingResolver.ResolveCall.Receives.PlatformDir).To(Equal("platform-dir"))
 
 				Expect(buffer.String()).To(ContainSubstring("Loading service binding of type 'some-typ'"))
+				Expect(buffer.String()).To(ContainSubstring("Using binding 'first'"))
+			})
+		})
+
+		context("when there are several npmrc bindings", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{
+						Name: "private-b",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("@b:registry=https://b.example.com/\n//b.example.com/:_authToken=b-token\n")),
+						},
+					},
+					{
+						Name: "mirror",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("# public mirror\nregistry=https://mirror.example.com/\n")),
+						},
+					},
+					{
+						Name: "private-a",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("registry = https://mirror.example.com/\n@a:registry=https://a.example.com/\n//a.example.com/:_authToken=a-token\n")),
+						},
+					},
+				}
+			})
+
+			it("merges them in the order of their names", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Base(path)).To(Equal(".npmrc"))
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(`# public mirror
+registry=https://mirror.example.com/
+@a:registry=https://a.example.com/
+//a.example.com/:_authToken=a-token
+@b:registry=https://b.example.com/
+//b.example.com/:_authToken=b-token
+`))
+
+				info, err := os.Stat(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+				Expect(buffer.String()).To(ContainSubstring("Loading service bindings of type 'npmrc'"))
+				Expect(buffer.String()).To(ContainLines(
+					"    Merged binding 'mirror'",
+					"    Merged binding 'private-a'",
+					"    Merged binding 'private-b'",
+				))
+
+				Expect(cleanup()).To(Succeed())
+				Expect(filepath.Dir(path)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("when npmrc bindings repeat a setting", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{
+						Name: "second",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("ca[]=\"some-other-cert\"\n")),
+						},
+					},
+					{
+						Name: "first",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("ca[]=\"some-cert\"\nca[]=\"another-cert\"\nstrict-ssl=false\nstrict-ssl=true\n")),
+						},
+					},
+				}
+			})
+
+			it("keeps the values of array settings and of settings repeated within a binding", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(`ca[]="some-cert"
+ca[]="another-cert"
+strict-ssl=false
+strict-ssl=true
+ca[]="some-other-cert"
+`))
+
+				Expect(cleanup()).To(Succeed())
+			})
+		})
+
+		context("when npmrc bindings hold lines that are not settings", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{
+						Name: "first",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("; private registry\nregistry=https://private.example.com/\n")),
+						},
+					},
+					{
+						Name: "second",
+						Type: "npmrc",
+						Entries: map[string]*servicebindings.Entry{
+							".npmrc": servicebindings.NewWithValue([]byte("[some-section]\n@a:registry=https://a.example.com/\n")),
+						},
+					},
+				}
+			})
+
+			it("keeps them verbatim", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(`; private registry
+registry=https://private.example.com/
+[some-section]
+@a:registry=https://a.example.com/
+`))
+
+				Expect(cleanup()).To(Succeed())
+			})
+		})
+
+		context("when there are several yarnrc bindings", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{
+						Name: "second",
+						Entries: map[string]*servicebindings.Entry{
+							".yarnrc": servicebindings.NewWithValue([]byte("\"@private:registry\" \"https://private.example.com/\"\n")),
+						},
+					},
+					{
+						Name: "first",
+						Entries: map[string]*servicebindings.Entry{
+							".yarnrc": servicebindings.NewWithValue([]byte("registry \"https://mirror.example.com/\"\n")),
+						},
+					},
+				}
+			})
+
+			it("merges them", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("yarnrc", "platform-dir", ".yarnrc")
+				Expect(err).NotTo(HaveOccurred())
+				defer cleanup()
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("registry \"https://mirror.example.com/\"\n\"@private:registry\" \"https://private.example.com/\"\n"))
+			})
+		})
+
+		context("when there are several yarnrc-yml bindings", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{
+						Name: "private",
+						Entries: map[string]*servicebindings.Entry{
+							".yarnrc.yml": servicebindings.NewWithValue([]byte(`npmScopes:
+  private:
+    npmRegistryServer: https://private.example.com
+    npmAuthToken: some-token
+`)),
+						},
+					},
+					{
+						Name: "mirror",
+						Entries: map[string]*servicebindings.Entry{
+							".yarnrc.yml": servicebindings.NewWithValue([]byte(`npmRegistryServer: https://mirror.example.com
+npmScopes:
+  public:
+    npmRegistryServer: https://mirror.example.com
+`)),
+						},
+					},
+				}
+			})
+
+			it("merges their scopes", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("yarnrc-yml", "platform-dir", ".yarnrc.yml")
+				Expect(err).NotTo(HaveOccurred())
+				defer cleanup()
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchYAML(`npmRegistryServer: https://mirror.example.com
+npmScopes:
+  public:
+    npmRegistryServer: https://mirror.example.com
+  private:
+    npmRegistryServer: https://private.example.com
+    npmAuthToken: some-token
+`))
+			})
+		})
+
+		context("when there is an npm-registry binding", func() {
+			it.Before(func() {
+				registryBindings = []servicebindings.Binding{
+					{
+						Name: "private",
+						Type: "npm-registry",
+						Path: "/bindings/private",
+						Entries: map[string]*servicebindings.Entry{
+							"registry":    servicebindings.NewWithValue([]byte("https://npm.example.com/repo\n")),
+							"scope":       servicebindings.NewWithValue([]byte("@private")),
+							"auth-token":  servicebindings.NewWithValue([]byte("some-token")),
+							"always-auth": servicebindings.NewWithValue([]byte("true")),
+							"ca":          servicebindings.NewWithValue([]byte("some-certificate")),
+						},
+					},
+				}
+			})
+
+			it("renders it as an .npmrc", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(`@private:registry=https://npm.example.com/repo
+//npm.example.com/repo/:_authToken=some-token
+//npm.example.com/repo/:always-auth=true
+cafile=/bindings/private/ca
+`))
+
+				Expect(buffer.String()).To(ContainLines(
+					"  Rendering service bindings of type 'npm-registry' as .npmrc",
+					"    Merged binding 'private'",
+				))
+
+				Expect(cleanup()).To(Succeed())
+				Expect(filepath.Dir(path)).NotTo(BeAnExistingFile())
+			})
+
+			it("renders it as a .yarnrc", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("yarnrc", "platform-dir", ".yarnrc")
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(`"@private:registry" "https://npm.example.com/repo"
+"//npm.example.com/repo/:_authToken" "some-token"
+"//npm.example.com/repo/:always-auth" true
+cafile "/bindings/private/ca"
+`))
+
+				Expect(cleanup()).To(Succeed())
+				Expect(filepath.Dir(path)).NotTo(BeAnExistingFile())
+			})
+
+			it("renders it as a .yarnrc.yml", func() {
+				path, cleanup, err := packageManagerConfigurationManager.DeterminePath("yarnrc-yml", "platform-dir", ".yarnrc.yml")
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchYAML(`httpsCaFilePath: /bindings/private/ca
+npmScopes:
+  private:
+    npmRegistryServer: https://npm.example.com/repo
+    npmAuthToken: some-token
+    npmAlwaysAuth: true
+`))
+
+				Expect(cleanup()).To(Succeed())
+				Expect(filepath.Dir(path)).NotTo(BeAnExistingFile())
+			})
+
+			context("when it has no scope and other bindings are present", func() {
+				it.Before(func() {
+					registryBindings = []servicebindings.Binding{
+						{
+							Name: "mirror",
+							Type: "npm-registry",
+							Entries: map[string]*servicebindings.Entry{
+								"registry": servicebindings.NewWithValue([]byte("https://mirror.example.com/")),
+							},
+						},
+					}
+
+					bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+						{
+							Name: "private",
+							Type: "yarnrc-yml",
+							Entries: map[string]*servicebindings.Entry{
+								".yarnrc.yml": servicebindings.NewWithValue([]byte("npmScopes:\n  private:\n    npmAuthToken: some-token\n")),
+							},
+						},
+					}
+				})
+
+				it("merges it with them", func() {
+					path, cleanup, err := packageManagerConfigurationManager.DeterminePath("yarnrc-yml", "platform-dir", ".yarnrc.yml")
+					Expect(err).NotTo(HaveOccurred())
+					defer cleanup()
+
+					content, err := os.ReadFile(path)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(MatchYAML(`npmRegistryServer: https://mirror.example.com/
+npmScopes:
+  private:
+    npmAuthToken: some-token
+`))
+
+					Expect(buffer.String()).To(ContainLines(
+						"    Merged binding 'mirror'",
+						"    Merged binding 'private'",
+					))
+				})
 			})
 		})
 
 		context("failure cases", func() {
+			context("when the npm-registry binding has no registry", func() {
+				it.Before(func() {
+					registryBindings = []servicebindings.Binding{
+						{
+							Name: "private",
+							Entries: map[string]*servicebindings.Entry{
+								"auth-token": servicebindings.NewWithValue([]byte("some-token")),
+							},
+						},
+					}
+				})
+
+				it("returns an error", func() {
+					_, _, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+					Expect(err).To(MatchError("failed: binding of type 'npm-registry' does not contain required entry 'registry'"))
+				})
+			})
+
+			context("when the always-auth entry of the npm-registry binding is not a boolean", func() {
+				it.Before(func() {
+					registryBindings = []servicebindings.Binding{
+						{
+							Name: "private",
+							Entries: map[string]*servicebindings.Entry{
+								"registry":    servicebindings.NewWithValue([]byte("https://npm.example.com/")),
+								"always-auth": servicebindings.NewWithValue([]byte("sometimes")),
+							},
+						},
+					}
+				})
+
+				it("returns an error", func() {
+					_, _, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+					Expect(err).To(MatchError(ContainSubstring("failed to parse entry 'always-auth' of binding 'private'")))
+				})
+			})
+
+			context("when two npm-registry bindings configure the same scope differently", func() {
+				it.Before(func() {
+					registryBindings = []servicebindings.Binding{
+						{
+							Name: "first",
+							Entries: map[string]*servicebindings.Entry{
+								"registry": servicebindings.NewWithValue([]byte("https://first.example.com/")),
+								"scope":    servicebindings.NewWithValue([]byte("private")),
+							},
+						},
+						{
+							Name: "second",
+							Entries: map[string]*servicebindings.Entry{
+								"registry": servicebindings.NewWithValue([]byte("https://second.example.com/")),
+								"scope":    servicebindings.NewWithValue([]byte("@private")),
+							},
+						},
+					}
+				})
+
+				it("returns an error", func() {
+					_, _, err := packageManagerConfigurationManager.DeterminePath("yarnrc-yml", "platform-dir", ".yarnrc.yml")
+					Expect(err).To(MatchError("failed: bindings 'first' and 'second' of type 'yarnrc-yml' both configure 'npmScopes.private.npmRegistryServer'"))
+				})
+			})
+
 			context("when the binding resolver fails", func() {
 				it.Before(func() {
 					bindingResolver.ResolveCall.Returns.Error = errors.New("failed to resolve binding")
 				})
 				it("returns an error", func() {
-					_, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
+					_, _, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
 					Expect(err).To(MatchError("failed to resolve binding"))
 				})
 			})
 
-			context("when more than one binding is found", func() {
+			context("when more than one binding of a type that cannot be merged is found", func() {
 				it.Before(func() {
 					bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
 						{
@@ -79,7 +477,7 @@ func testPackageManagerConfigurationManager(t *testing.T, context spec.G, it spe
 							Type: "some-typ",
 							Path: "some-binding-path",
 							Entries: map[string]*servicebindings.Entry{
-								"some-entry": servicebindings.NewEntry("some-path"),
+								"some-entry": servicebindings.NewWithValue([]byte("some-content")),
 							},
 						},
 						{
@@ -87,14 +485,83 @@ func testPackageManagerConfigurationManager(t *testing.T, context spec.G, it spe
 							Type: "some-typ",
 							Path: "some-binding-path",
 							Entries: map[string]*servicebindings.Entry{
-								"another-entry": servicebindings.NewEntry("some-path"),
+								"some-entry": servicebindings.NewWithValue([]byte("some-content")),
+							},
+						},
+					}
+				})
+				it("returns an error", func() {
+					_, _, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
+					Expect(err).To(MatchError("failed: cannot merge more than one binding of type 'some-typ'"))
+				})
+			})
+
+			context("when one of several bindings is missing the required entry", func() {
+				it.Before(func() {
+					bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+						{
+							Name: "first",
+							Entries: map[string]*servicebindings.Entry{
+								".npmrc": servicebindings.NewWithValue([]byte("registry=https://mirror.example.com/")),
+							},
+						},
+						{
+							Name: "second",
+							Entries: map[string]*servicebindings.Entry{
+								"other-entry": servicebindings.NewWithValue([]byte("some-content")),
 							},
 						},
 					}
 				})
 				it("returns an error", func() {
-					_, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
-					Expect(err).To(MatchError("failed: binding resolver found more than one binding of type 'some-typ'"))
+					_, _, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+					Expect(err).To(MatchError("failed: binding of type 'npmrc' does not contain required entry '.npmrc'"))
+				})
+			})
+
+			context("when two npmrc bindings configure the same scope differently", func() {
+				it.Before(func() {
+					bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+						{
+							Name: "second",
+							Entries: map[string]*servicebindings.Entry{
+								".npmrc": servicebindings.NewWithValue([]byte("@private:registry=https://other.example.com/")),
+							},
+						},
+						{
+							Name: "first",
+							Entries: map[string]*servicebindings.Entry{
+								".npmrc": servicebindings.NewWithValue([]byte("@private:registry=https://private.example.com/")),
+							},
+						},
+					}
+				})
+				it("returns an error", func() {
+					_, _, err := packageManagerConfigurationManager.DeterminePath("npmrc", "platform-dir", ".npmrc")
+					Expect(err).To(MatchError("failed: bindings 'first' and 'second' of type 'npmrc' both configure '@private:registry'"))
+				})
+			})
+
+			context("when two yarnrc-yml bindings configure the same scope differently", func() {
+				it.Before(func() {
+					bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+						{
+							Name: "first",
+							Entries: map[string]*servicebindings.Entry{
+								".yarnrc.yml": servicebindings.NewWithValue([]byte("npmScopes:\n  private:\n    npmAuthToken: first-token\n")),
+							},
+						},
+						{
+							Name: "second",
+							Entries: map[string]*servicebindings.Entry{
+								".yarnrc.yml": servicebindings.NewWithValue([]byte("npmScopes:\n  private:\n    npmAuthToken: second-token\n")),
+							},
+						},
+					}
+				})
+				it("returns an error without the conflicting values", func() {
+					_, _, err := packageManagerConfigurationManager.DeterminePath("yarnrc-yml", "platform-dir", ".yarnrc.yml")
+					Expect(err).To(MatchError("failed: bindings 'first' and 'second' of type 'yarnrc-yml' both configure 'npmScopes.private.npmAuthToken'"))
 				})
 			})
 
@@ -112,7 +579,7 @@ func testPackageManagerConfigurationManager(t *testing.T, context spec.G, it spe
 					}
 				})
 				it("returns an error", func() {
-					_, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
+					_, _, err := packageManagerConfigurationManager.DeterminePath("some-typ", "platform-dir", "some-entry")
 					Expect(err).To(MatchError("failed: binding of type 'some-typ' does not contain required entry 'some-entry'"))
 				})
 			})
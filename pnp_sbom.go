@@ -0,0 +1,146 @@
+package yarninstall
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// pnpPackages returns the packages of a Plug'n'Play install.
+func pnpPackages(projectPath, modulesLayerPath string, lf lockfile.Lockfile, config *YarnrcConfig) ([]installedPackage, error) {
+	entries := berryArchiveIndex(lf)
+
+	cacheFolder := ".yarn/cache"
+	if config != nil && config.CacheFolder != "" {
+		cacheFolder = config.CacheFolder
+	}
+
+	projectCache := filepath.Join(projectPath, filepath.FromSlash(cacheFolder))
+	cacheDirs := []string{filepath.Join(modulesLayerPath, "cache")}
+
+	// The cache folder of the project is a link to the cache of the layer
+	// unless the project brings its own cache
+	info, err := os.Lstat(projectCache)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if info != nil && info.Mode()&os.ModeSymlink == 0 {
+		cacheDirs = append(cacheDirs, projectCache)
+	}
+
+	var packages []installedPackage
+	archived := map[string]bool{}
+	for _, dir := range cacheDirs {
+		archives, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read yarn cache: %w", err)
+		}
+
+		for _, archive := range archives {
+			name := archive.Name()
+			if archive.IsDir() || !strings.HasSuffix(name, ".zip") || archived[name] {
+				continue
+			}
+
+			entry, ok := berryArchivePackage(name, entries, lf.Metadata.CacheKey)
+			if !ok || strings.Contains(entry.Resolved, "@workspace:") {
+				continue
+			}
+
+			checksum, err := archiveChecksum(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+
+			location := filepath.Join(dir, name)
+			if dir != projectCache {
+				location = filepath.Join("/cache", name)
+			}
+
+			archived[name] = true
+			packages = append(packages, installedPackage{
+				name:     entry.Name,
+				version:  entry.Version,
+				location: filepath.ToSlash(location),
+				checksum: checksum,
+			})
+		}
+	}
+
+	unplugged, err := unpluggedPackages(filepath.Join(modulesLayerPath, "pnp"), modulesLayerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if unplugged == nil {
+		unplugged, err = unpluggedPackages(projectPath, "/")
+		if err != nil {
+			return nil, err
+		}
+	}
+	packages = append(packages, unplugged...)
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].location < packages[j].location
+	})
+
+	return packages, nil
+}
+
+// unpluggedPackages returns the packages in .yarn/unplugged.
+func unpluggedPackages(dir, base string) ([]installedPackage, error) {
+	folders, err := os.ReadDir(filepath.Join(dir, filepath.FromSlash(YarnUnplugged)))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read unplugged packages: %w", err)
+	}
+
+	packages := []installedPackage{}
+	for _, folder := range folders {
+		if !folder.IsDir() {
+			continue
+		}
+
+		found, err := collectNodeModules(filepath.Join(dir, filepath.FromSlash(YarnUnplugged), folder.Name(), "node_modules"), base)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range found {
+			p.unplugged = true
+			packages = append(packages, p)
+		}
+	}
+
+	return packages, nil
+}
+
+func archiveChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open package archive: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha512.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash package archive: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
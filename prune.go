@@ -0,0 +1,265 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// PruneDevDependencies removes the packages that only devDependencies need
+// from a copy of the build node_modules and returns how many it removed.
+func PruneDevDependencies(projectPath, modulesLayerPath string) (int, error) {
+	lf, err := lockfile.Parse(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil {
+		return 0, err
+	}
+
+	keep, err := productionPackages(projectPath, lf)
+	if err != nil {
+		return 0, err
+	}
+
+	roots := []string{filepath.Join(modulesLayerPath, "node_modules")}
+
+	// Workspace node_modules are kept next to the root node_modules
+	err = filepath.WalkDir(filepath.Join(modulesLayerPath, "workspaces"), func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if entry.IsDir() && entry.Name() == "node_modules" {
+			roots = append(roots, path)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find workspace node_modules: %w", err)
+	}
+
+	var removed int
+	for _, root := range roots {
+		count, err := pruneNodeModules(root, keep)
+		if err != nil {
+			return removed, err
+		}
+		removed += count
+
+		// The install state describes the unpruned tree
+		for _, state := range []string{".yarn-state.yml", ".yarn-integrity"} {
+			err = os.Remove(filepath.Join(root, state))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return removed, fmt.Errorf("failed to remove %s: %w", state, err)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// productionPackages returns the name@version of every production package.
+func productionPackages(projectPath string, lf lockfile.Lockfile) (map[string]bool, error) {
+	manifests, err := projectManifests(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{}
+	visited := map[string]bool{}
+
+	var visit func(name, rng string)
+	visit = func(name, rng string) {
+		pkg, ok := lookupDependency(lf, name, rng)
+		if !ok {
+			return
+		}
+
+		keep[name+"@"+pkg.Version] = true
+		keep[pkg.Name+"@"+pkg.Version] = true
+
+		// Berry lists the devDependencies of workspaces in their entries, the
+		// production dependencies of workspaces are visited from their
+		// manifests instead
+		if strings.Contains(pkg.Resolved, "@workspace:") {
+			return
+		}
+
+		if visited[pkg.Descriptors[0]] {
+			return
+		}
+		visited[pkg.Descriptors[0]] = true
+
+		for dependency, dependencyRange := range pkg.Dependencies {
+			visit(dependency, dependencyRange)
+		}
+
+		for dependency, dependencyRange := range pkg.OptionalDependencies {
+			visit(dependency, dependencyRange)
+		}
+	}
+
+	for _, m := range manifests {
+		for name, rng := range m.Dependencies {
+			visit(name, rng)
+		}
+
+		for name, rng := range m.OptionalDependencies {
+			visit(name, rng)
+		}
+	}
+
+	return keep, nil
+}
+
+// projectManifests returns the package.json of the project followed by the
+// package.json files of its workspaces.
+func projectManifests(projectPath string) ([]PackageManifest, error) {
+	manifest, err := ParsePackageManifest(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []PackageManifest{manifest}
+
+	workspaces, err := manifest.WorkspaceDirs(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, workspace := range workspaces {
+		workspaceManifest, err := ParsePackageManifest(filepath.Join(projectPath, workspace))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, workspaceManifest)
+	}
+
+	return manifests, nil
+}
+
+// pruneNodeModules removes the packages in dir that are not in keep.
+func pruneNodeModules(dir string, keep map[string]bool) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read node_modules directory: %w", err)
+	}
+
+	var removed int
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Dot entries hold yarn metadata, the .bin links and the pnpm store
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if strings.HasPrefix(name, "@") && entry.IsDir() {
+			scoped, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return removed, fmt.Errorf("failed to read scope directory: %w", err)
+			}
+
+			for _, scopedEntry := range scoped {
+				count, err := prunePackage(filepath.Join(dir, name, scopedEntry.Name()), name+"/"+scopedEntry.Name(), scopedEntry, keep)
+				if err != nil {
+					return removed, err
+				}
+				removed += count
+			}
+
+			remaining, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return removed, fmt.Errorf("failed to read scope directory: %w", err)
+			}
+
+			if len(remaining) == 0 {
+				err = os.Remove(filepath.Join(dir, name))
+				if err != nil {
+					return removed, fmt.Errorf("failed to remove scope directory: %w", err)
+				}
+			}
+
+			continue
+		}
+
+		count, err := prunePackage(filepath.Join(dir, name), name, entry, keep)
+		if err != nil {
+			return removed, err
+		}
+		removed += count
+	}
+
+	err = removeDanglingBinLinks(filepath.Join(dir, ".bin"))
+	if err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+func prunePackage(path, name string, entry os.DirEntry, keep map[string]bool) (int, error) {
+	// Links point at workspaces or other local packages, which are not part
+	// of the lockfile graph
+	if !entry.IsDir() {
+		return 0, nil
+	}
+
+	version, err := installedVersion(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read installed version of %s: %w", name, err)
+	}
+
+	if !keep[name+"@"+version] {
+		err = os.RemoveAll(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+
+		return 1, nil
+	}
+
+	return pruneNodeModules(filepath.Join(path, "node_modules"), keep)
+}
+
+func removeDanglingBinLinks(binDir string) error {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read .bin directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(binDir, entry.Name())
+		_, err := os.Stat(path)
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPrune(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		projectDir string
+		layerDir   string
+	)
+
+	writePackage := func(path, version string) {
+		Expect(os.MkdirAll(path, os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(path, "package.json"), []byte(`{"version": "`+version+`"}`), 0600)).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		projectDir, err = os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+
+		layerDir, err = os.MkdirTemp("", "layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{
+			"workspaces": ["packages/*"],
+			"dependencies": {
+				"express": "^4.18.0"
+			},
+			"devDependencies": {
+				"@types/node": "^20.0.0",
+				"jest": "^29.0.0"
+			}
+		}`), 0600)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(projectDir, "packages", "a"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(projectDir, "packages", "a", "package.json"), []byte(`{
+			"dependencies": {
+				"leftpad": "~0.0.1"
+			}
+		}`), 0600)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+"@types/node@^20.0.0":
+  version "20.1.0"
+
+debug@2.6.9:
+  version "2.6.9"
+  dependencies:
+    ms "2.0.0"
+
+debug@^4.3.0:
+  version "4.3.4"
+
+express@^4.18.0:
+  version "4.18.2"
+  dependencies:
+    debug "2.6.9"
+
+jest@^29.0.0:
+  version "29.7.0"
+  dependencies:
+    debug "^4.3.0"
+
+leftpad@~0.0.1:
+  version "0.0.1"
+
+ms@2.0.0:
+  version "2.0.0"
+`), 0600)).To(Succeed())
+
+		nodeModules := filepath.Join(layerDir, "node_modules")
+		writePackage(filepath.Join(nodeModules, "@types", "node"), "20.1.0")
+		writePackage(filepath.Join(nodeModules, "debug"), "4.3.4")
+		writePackage(filepath.Join(nodeModules, "express"), "4.18.2")
+		writePackage(filepath.Join(nodeModules, "express", "node_modules", "debug"), "2.6.9")
+		writePackage(filepath.Join(nodeModules, "jest"), "29.7.0")
+		writePackage(filepath.Join(nodeModules, "leftpad"), "0.0.1")
+		writePackage(filepath.Join(nodeModules, "ms"), "2.0.0")
+
+		Expect(os.MkdirAll(filepath.Join(nodeModules, ".bin"), os.ModePerm)).To(Succeed())
+		Expect(os.Symlink("../jest/bin/jest.js", filepath.Join(nodeModules, ".bin", "jest"))).To(Succeed())
+		Expect(os.Symlink("../express/package.json", filepath.Join(nodeModules, ".bin", "express"))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(nodeModules, ".yarn-integrity"), []byte("{}"), 0600)).To(Succeed())
+
+		Expect(os.Symlink(filepath.Join(projectDir, "packages", "a"), filepath.Join(nodeModules, "a"))).To(Succeed())
+
+		writePackage(filepath.Join(layerDir, "workspaces", "packages", "a", "node_modules", "jest"), "29.7.0")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(projectDir)).To(Succeed())
+		Expect(os.RemoveAll(layerDir)).To(Succeed())
+	})
+
+	context("PruneDevDependencies", func() {
+		it("removes the packages that only devDependencies need", func() {
+			removed, err := yarninstall.PruneDevDependencies(projectDir, layerDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(4))
+
+			nodeModules := filepath.Join(layerDir, "node_modules")
+			Expect(filepath.Join(nodeModules, "express")).To(BeADirectory())
+			Expect(filepath.Join(nodeModules, "express", "node_modules", "debug")).To(BeADirectory())
+			Expect(filepath.Join(nodeModules, "ms")).To(BeADirectory())
+			Expect(filepath.Join(nodeModules, "leftpad")).To(BeADirectory())
+			Expect(filepath.Join(nodeModules, "a")).To(BeADirectory())
+
+			Expect(filepath.Join(nodeModules, "jest")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(nodeModules, "debug")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(nodeModules, "@types")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(layerDir, "workspaces", "packages", "a", "node_modules", "jest")).NotTo(BeAnExistingFile())
+
+			_, err = os.Lstat(filepath.Join(nodeModules, ".bin", "jest"))
+			Expect(err).To(MatchError(os.ErrNotExist))
+			Expect(filepath.Join(nodeModules, ".bin", "express")).To(BeAnExistingFile())
+
+			Expect(filepath.Join(nodeModules, ".yarn-integrity")).NotTo(BeAnExistingFile())
+		})
+
+		context("failure cases", func() {
+			context("when the yarn.lock cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte("  version \"1.0.0\"\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.PruneDevDependencies(projectDir, layerDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+
+			context("when an installed package.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layerDir, "node_modules", "jest", "package.json"), []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.PruneDevDependencies(projectDir, layerDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to read installed version of jest")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,13 @@
+package yarninstall
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink clones the content of source into destination with the FICLONE
+// ioctl, which fails on filesystems that do not share data blocks.
+func reflink(source, destination *os.File) error {
+	return unix.IoctlFileClone(int(destination.Fd()), int(source.Fd()))
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package yarninstall
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(source, destination *os.File) error {
+	return errors.ErrUnsupported
+}
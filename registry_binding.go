@@ -0,0 +1,149 @@
+package yarninstall
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+	"gopkg.in/yaml.v3"
+)
+
+// NpmRegistryBindingType is the type of registry bindings with discrete entries.
+const NpmRegistryBindingType = "npm-registry"
+
+// npmRegistry is the registry an npm-registry binding describes.
+type npmRegistry struct {
+	url        string
+	scope      string
+	authToken  string
+	alwaysAuth bool
+	caFile     string
+}
+
+func parseNpmRegistry(binding servicebindings.Binding) (npmRegistry, error) {
+	read := func(name string) (string, error) {
+		entry, ok := binding.Entries[name]
+		if !ok {
+			return "", nil
+		}
+
+		value, err := entry.ReadString()
+		if err != nil {
+			return "", fmt.Errorf("failed to read entry '%s' of binding '%s': %w", name, binding.Name, err)
+		}
+
+		return strings.TrimSpace(value), nil
+	}
+
+	var registry npmRegistry
+	var err error
+	registry.url, err = read("registry")
+	if err != nil {
+		return npmRegistry{}, err
+	}
+
+	if registry.url == "" {
+		return npmRegistry{}, fmt.Errorf("failed: binding of type '%s' does not contain required entry 'registry'", NpmRegistryBindingType)
+	}
+
+	registry.scope, err = read("scope")
+	if err != nil {
+		return npmRegistry{}, err
+	}
+	registry.scope = strings.TrimPrefix(registry.scope, "@")
+
+	registry.authToken, err = read("auth-token")
+	if err != nil {
+		return npmRegistry{}, err
+	}
+
+	alwaysAuth, err := read("always-auth")
+	if err != nil {
+		return npmRegistry{}, err
+	}
+
+	if alwaysAuth != "" {
+		registry.alwaysAuth, err = strconv.ParseBool(alwaysAuth)
+		if err != nil {
+			return npmRegistry{}, fmt.Errorf("failed to parse entry 'always-auth' of binding '%s': %w", binding.Name, err)
+		}
+	}
+
+	// The certificate is referenced where the binding mounts it
+	if _, ok := binding.Entries["ca"]; ok {
+		registry.caFile = filepath.Join(binding.Path, "ca")
+	}
+
+	return registry, nil
+}
+
+// render returns the registry in the syntax of the given entry.
+func (r npmRegistry) render(entry string) ([]byte, error) {
+	// Credentials are keyed by the registry URL without its protocol
+	nerfDart := "//" + r.url
+	if i := strings.Index(r.url, "//"); i >= 0 {
+		nerfDart = r.url[i:]
+	}
+	nerfDart = strings.TrimSuffix(nerfDart, "/") + "/"
+
+	registryKey := "registry"
+	if r.scope != "" {
+		registryKey = fmt.Sprintf("@%s:registry", r.scope)
+	}
+
+	switch entry {
+	case ".npmrc":
+		lines := []string{fmt.Sprintf("%s=%s", registryKey, r.url)}
+		if r.authToken != "" {
+			lines = append(lines, fmt.Sprintf("%s:_authToken=%s", nerfDart, r.authToken))
+		}
+		if r.alwaysAuth {
+			lines = append(lines, fmt.Sprintf("%s:always-auth=true", nerfDart))
+		}
+		if r.caFile != "" {
+			lines = append(lines, fmt.Sprintf("cafile=%s", r.caFile))
+		}
+		return []byte(strings.Join(lines, "\n") + "\n"), nil
+
+	case ".yarnrc":
+		lines := []string{fmt.Sprintf("%q %q", registryKey, r.url)}
+		if r.authToken != "" {
+			lines = append(lines, fmt.Sprintf("%q %q", nerfDart+":_authToken", r.authToken))
+		}
+		if r.alwaysAuth {
+			lines = append(lines, fmt.Sprintf("%q true", nerfDart+":always-auth"))
+		}
+		if r.caFile != "" {
+			lines = append(lines, fmt.Sprintf("cafile %q", r.caFile))
+		}
+		return []byte(strings.Join(lines, "\n") + "\n"), nil
+
+	case YarnrcYml:
+		settings := map[string]interface{}{"npmRegistryServer": r.url}
+		if r.authToken != "" {
+			settings["npmAuthToken"] = r.authToken
+		}
+		if r.alwaysAuth {
+			settings["npmAlwaysAuth"] = true
+		}
+
+		config := settings
+		if r.scope != "" {
+			config = map[string]interface{}{
+				"npmScopes": map[string]interface{}{r.scope: settings},
+			}
+		}
+
+		// The CA is set globally, so it applies to every registry
+		if r.caFile != "" {
+			config["httpsCaFilePath"] = r.caFile
+		}
+
+		return yaml.Marshal(config)
+
+	default:
+		return nil, fmt.Errorf("failed: cannot render binding of type '%s' as '%s'", NpmRegistryBindingType, entry)
+	}
+}
@@ -0,0 +1,449 @@
+package yarninstall
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
+)
+
+// DefaultNpmRegistry is the registry that Yarn downloads packages from when
+// npmRegistryServer is not configured.
+const DefaultNpmRegistry = "https://registry.yarnpkg.com"
+
+// The CycloneDX scopes of a package.
+const (
+	scopeRequired = "required"
+	scopeOptional = "optional"
+	scopeExcluded = "excluded"
+)
+
+var sha1Pattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// dependencyGraph holds the scope, source, hashes and dependencies of the
+// packages of a modules layer, keyed by package URL.
+type dependencyGraph struct {
+	packages map[string]graphPackage
+}
+
+type graphPackage struct {
+	scope     string
+	url       string
+	hashes    []packageHash
+	dependsOn []string
+
+	// unplugged packages of a Plug'n'Play install were extracted from
+	// their archive into .yarn/unplugged
+	unplugged bool
+
+	// direct packages are dependencies of the project or one of its
+	// workspaces, dev packages are direct packages that only
+	// devDependencies need
+	direct bool
+	dev    bool
+}
+
+type packageHash struct {
+	algorithm string
+	value     string
+}
+
+// buildDependencyGraph computes the graph of the installed packages.
+func buildDependencyGraph(manifests []PackageManifest, lf lockfile.Lockfile, registry string, installed map[string]bool) dependencyGraph {
+	key := func(pkg lockfile.Package) string {
+		return pkg.Name + "@" + pkg.Version
+	}
+
+	isWorkspace := func(pkg lockfile.Package) bool {
+		return strings.Contains(pkg.Resolved, "@workspace:")
+	}
+
+	reach := func(roots []map[string]string, followOptional bool) map[string]bool {
+		reached := map[string]bool{}
+
+		var visit func(name, rng string)
+		visit = func(name, rng string) {
+			pkg, ok := lookupDependency(lf, name, rng)
+
+			// Workspaces are reached through their manifests
+			if !ok || isWorkspace(pkg) || reached[key(pkg)] {
+				return
+			}
+			reached[key(pkg)] = true
+
+			for dependency, dependencyRange := range pkg.Dependencies {
+				visit(dependency, dependencyRange)
+			}
+
+			if followOptional {
+				for dependency, dependencyRange := range pkg.OptionalDependencies {
+					visit(dependency, dependencyRange)
+				}
+			}
+		}
+
+		for _, dependencies := range roots {
+			for name, rng := range dependencies {
+				visit(name, rng)
+			}
+		}
+
+		return reached
+	}
+
+	var production, optional, development []map[string]string
+	for _, m := range manifests {
+		production = append(production, m.Dependencies)
+		optional = append(optional, m.Dependencies, m.OptionalDependencies, m.PeerDependencies)
+		development = append(development, m.DevDependencies)
+	}
+
+	required := reach(production, false)
+	reachable := reach(optional, true)
+
+	graph := dependencyGraph{packages: map[string]graphPackage{}}
+	for _, pkg := range lf.Packages {
+		if !installed[key(pkg)] || isWorkspace(pkg) {
+			continue
+		}
+
+		node := graphPackage{scope: scopeExcluded}
+		switch {
+		case required[key(pkg)]:
+			node.scope = scopeRequired
+		case reachable[key(pkg)]:
+			node.scope = scopeOptional
+		}
+
+		node.url, node.hashes = packageSource(lf, pkg, registry)
+
+		dependsOn := map[string]bool{}
+		for _, dependencies := range []map[string]string{pkg.Dependencies, pkg.OptionalDependencies} {
+			for name, rng := range dependencies {
+				dependency, ok := lookupDependency(lf, name, rng)
+				if ok && installed[key(dependency)] && !isWorkspace(dependency) {
+					dependsOn[npmPURL(dependency.Name, dependency.Version)] = true
+				}
+			}
+		}
+
+		for purl := range dependsOn {
+			node.dependsOn = append(node.dependsOn, purl)
+		}
+		sort.Strings(node.dependsOn)
+
+		graph.packages[npmPURL(pkg.Name, pkg.Version)] = node
+	}
+
+	for _, roots := range [][]map[string]string{production, optional, development} {
+		for _, dependencies := range roots {
+			for name, rng := range dependencies {
+				pkg, ok := lookupDependency(lf, name, rng)
+				if !ok {
+					continue
+				}
+
+				purl := npmPURL(pkg.Name, pkg.Version)
+				node, ok := graph.packages[purl]
+				if !ok {
+					continue
+				}
+
+				node.direct = true
+				node.dev = node.scope == scopeExcluded
+				graph.packages[purl] = node
+			}
+		}
+	}
+
+	return graph
+}
+
+// setArchive records the Plug'n'Play archive of a package.
+func (g dependencyGraph) setArchive(purl, checksum string, unplugged bool) {
+	node, ok := g.packages[purl]
+	if !ok {
+		return
+	}
+
+	if checksum != "" {
+		node.hashes = []packageHash{{algorithm: "SHA-512", value: checksum}}
+	}
+	node.unplugged = unplugged
+
+	g.packages[purl] = node
+}
+
+// packageSource returns the download URL and hashes of a package.
+func packageSource(lf lockfile.Lockfile, pkg lockfile.Package, registry string) (string, []packageHash) {
+	if lf.Format == lockfile.FormatBerry {
+		_, resolution := lockfile.SplitDescriptor(pkg.Resolved)
+		switch {
+		case strings.HasPrefix(resolution, "npm:"):
+			base := pkg.Name[strings.LastIndex(pkg.Name, "/")+1:]
+			return fmt.Sprintf("%s/%s/-/%s-%s.tgz", strings.TrimSuffix(registry, "/"), pkg.Name, base, strings.TrimPrefix(resolution, "npm:")), nil
+		case strings.HasPrefix(resolution, "https://"), strings.HasPrefix(resolution, "http://"):
+			return resolution, nil
+		default:
+			return "", nil
+		}
+	}
+
+	url, fragment, _ := strings.Cut(pkg.Resolved, "#")
+	if !strings.Contains(url, "://") {
+		url = ""
+	}
+
+	var hashes []packageHash
+	for _, token := range strings.Fields(pkg.Integrity) {
+		algorithm, digest, ok := strings.Cut(token, "-")
+		if !ok {
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+
+		switch algorithm {
+		case "sha1", "sha256", "sha384", "sha512":
+			hashes = append(hashes, packageHash{
+				algorithm: "SHA-" + strings.TrimPrefix(algorithm, "sha"),
+				value:     hex.EncodeToString(content),
+			})
+		}
+	}
+
+	if sha1Pattern.MatchString(fragment) && !hasAlgorithm(hashes, "SHA-1") {
+		hashes = append(hashes, packageHash{algorithm: "SHA-1", value: fragment})
+	}
+
+	return url, hashes
+}
+
+func hasAlgorithm(hashes []packageHash, algorithm string) bool {
+	for _, hash := range hashes {
+		if hash.algorithm == algorithm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// annotate adds the graph to an SBOM formatted by syft.
+func (g dependencyGraph) annotate(extension string, content []byte) ([]byte, error) {
+	if len(g.packages) == 0 {
+		return content, nil
+	}
+
+	switch extension {
+	case "cdx.json":
+		return g.annotateCycloneDX(content)
+	case "spdx.json":
+		return g.annotateSPDX(content)
+	default:
+		return content, nil
+	}
+}
+
+// annotateCycloneDX adds the graph to a CycloneDX SBOM.
+func (g dependencyGraph) annotateCycloneDX(content []byte) ([]byte, error) {
+	var document map[string]interface{}
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CycloneDX SBOM: %w", err)
+	}
+
+	refs := map[string]string{}
+	components, _ := document["components"].([]interface{})
+	for _, c := range components {
+		component, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		purl, _ := component["purl"].(string)
+		pkg, ok := g.packages[purl]
+		if !ok {
+			continue
+		}
+
+		refs[purl], _ = component["bom-ref"].(string)
+		component["scope"] = pkg.scope
+
+		if len(pkg.hashes) > 0 {
+			var hashes []interface{}
+			for _, hash := range pkg.hashes {
+				hashes = append(hashes, map[string]interface{}{
+					"alg":     hash.algorithm,
+					"content": hash.value,
+				})
+			}
+			component["hashes"] = hashes
+		}
+
+		if pkg.unplugged {
+			properties, _ := component["properties"].([]interface{})
+			component["properties"] = append(properties, map[string]interface{}{
+				"name":  "yarn:unplugged",
+				"value": "true",
+			})
+		}
+
+		if pkg.url != "" {
+			references, _ := component["externalReferences"].([]interface{})
+			component["externalReferences"] = append(references, map[string]interface{}{
+				"type": "distribution",
+				"url":  pkg.url,
+			})
+		}
+	}
+
+	var root string
+	if metadata, ok := document["metadata"].(map[string]interface{}); ok {
+		if component, ok := metadata["component"].(map[string]interface{}); ok {
+			root, _ = component["bom-ref"].(string)
+		}
+	}
+
+	dependencies := []interface{}{}
+	rootDependencies := []interface{}{}
+	for _, purl := range sortedKeys(refs) {
+		pkg := g.packages[purl]
+
+		dependsOn := []interface{}{}
+		for _, dependency := range pkg.dependsOn {
+			if ref, ok := refs[dependency]; ok {
+				dependsOn = append(dependsOn, ref)
+			}
+		}
+
+		dependencies = append(dependencies, map[string]interface{}{
+			"ref":       refs[purl],
+			"dependsOn": dependsOn,
+		})
+
+		if pkg.direct {
+			rootDependencies = append(rootDependencies, refs[purl])
+		}
+	}
+
+	if root != "" {
+		dependencies = append([]interface{}{map[string]interface{}{
+			"ref":       root,
+			"dependsOn": rootDependencies,
+		}}, dependencies...)
+	}
+
+	document["dependencies"] = dependencies
+
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// annotateSPDX adds the graph to an SPDX SBOM.
+func (g dependencyGraph) annotateSPDX(content []byte) ([]byte, error) {
+	var document map[string]interface{}
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SPDX SBOM: %w", err)
+	}
+
+	ids := map[string]string{}
+	packages, _ := document["packages"].([]interface{})
+	for _, p := range packages {
+		spdxPackage, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var purl string
+		references, _ := spdxPackage["externalRefs"].([]interface{})
+		for _, r := range references {
+			if reference, ok := r.(map[string]interface{}); ok && reference["referenceType"] == "purl" {
+				purl, _ = reference["referenceLocator"].(string)
+			}
+		}
+
+		pkg, ok := g.packages[purl]
+		if !ok {
+			continue
+		}
+
+		ids[purl], _ = spdxPackage["SPDXID"].(string)
+
+		if len(pkg.hashes) > 0 {
+			var checksums []interface{}
+			for _, hash := range pkg.hashes {
+				checksums = append(checksums, map[string]interface{}{
+					"algorithm":     strings.ReplaceAll(hash.algorithm, "-", ""),
+					"checksumValue": hash.value,
+				})
+			}
+			spdxPackage["checksums"] = checksums
+		}
+
+		if pkg.url != "" {
+			spdxPackage["downloadLocation"] = pkg.url
+		}
+
+		if pkg.unplugged {
+			spdxPackage["comment"] = "Unplugged by Yarn"
+		}
+	}
+
+	relationships, _ := document["relationships"].([]interface{})
+
+	var root string
+	for _, r := range relationships {
+		if relationship, ok := r.(map[string]interface{}); ok && relationship["spdxElementId"] == "SPDXRef-DOCUMENT" && relationship["relationshipType"] == "DESCRIBES" {
+			root, _ = relationship["relatedSpdxElement"].(string)
+		}
+	}
+
+	relationship := func(element, typ, related string) map[string]interface{} {
+		return map[string]interface{}{
+			"spdxElementId":      element,
+			"relationshipType":   typ,
+			"relatedSpdxElement": related,
+		}
+	}
+
+	for _, purl := range sortedKeys(ids) {
+		pkg := g.packages[purl]
+
+		if pkg.direct && root != "" {
+			if pkg.dev {
+				relationships = append(relationships, relationship(ids[purl], "DEV_DEPENDENCY_OF", root))
+			} else {
+				relationships = append(relationships, relationship(root, "DEPENDS_ON", ids[purl]))
+			}
+		}
+
+		for _, dependency := range pkg.dependsOn {
+			if id, ok := ids[dependency]; ok {
+				relationships = append(relationships, relationship(ids[purl], "DEPENDS_ON", id))
+			}
+		}
+	}
+
+	document["relationships"] = relationships
+
+	return json.MarshalIndent(document, "", " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
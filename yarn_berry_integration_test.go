@@ -44,7 +44,8 @@ func testYarnBerryIntegration(t *testing.T, context spec.G, it spec.S) {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(config).NotTo(BeNil())
 
-			provisionType := yarninstall.DetermineProvisionType(workingDir, config)
+			provisionType, err := yarninstall.DetermineProvisionType(workingDir, config)
+			Expect(err).NotTo(HaveOccurred())
 			Expect(provisionType).To(Equal(yarninstall.PlanDependencyYarnPkgs))
 		})
 	})
@@ -60,7 +61,8 @@ func testYarnBerryIntegration(t *testing.T, context spec.G, it spec.S) {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(config).NotTo(BeNil())
 
-			provisionType := yarninstall.DetermineProvisionType(workingDir, config)
+			provisionType, err := yarninstall.DetermineProvisionType(workingDir, config)
+			Expect(err).NotTo(HaveOccurred())
 			Expect(provisionType).To(Equal(yarninstall.PlanDependencyNodeModules))
 		})
 	})
@@ -82,7 +84,8 @@ func testYarnBerryIntegration(t *testing.T, context spec.G, it spec.S) {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(config).To(BeNil())
 
-			provisionType := yarninstall.DetermineProvisionType(workingDir, config)
+			provisionType, err := yarninstall.DetermineProvisionType(workingDir, config)
+			Expect(err).NotTo(HaveOccurred())
 			Expect(provisionType).To(Equal(yarninstall.PlanDependencyNodeModules))
 		})
 	})
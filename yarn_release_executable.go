@@ -0,0 +1,23 @@
+package yarninstall
+
+import (
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// YarnReleaseExecutable runs a Yarn release vendored into the project.
+type YarnReleaseExecutable struct {
+	node        Executable
+	releasePath string
+}
+
+func NewYarnReleaseExecutable(node Executable, releasePath string) YarnReleaseExecutable {
+	return YarnReleaseExecutable{
+		node:        node,
+		releasePath: releasePath,
+	}
+}
+
+func (e YarnReleaseExecutable) Execute(execution pexec.Execution) error {
+	execution.Args = append([]string{e.releasePath}, execution.Args...)
+	return e.node.Execute(execution)
+}
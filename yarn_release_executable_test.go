@@ -0,0 +1,59 @@
+package yarninstall_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/paketo-buildpacks/yarn-install/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testYarnReleaseExecutable(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		node       *fakes.Executable
+		executable yarninstall.YarnReleaseExecutable
+	)
+
+	it.Before(func() {
+		node = &fakes.Executable{}
+		executable = yarninstall.NewYarnReleaseExecutable(node, "/some/project/.yarn/releases/yarn-4.0.2.cjs")
+	})
+
+	it("runs the release with node", func() {
+		buffer := bytes.NewBuffer(nil)
+		err := executable.Execute(pexec.Execution{
+			Args:   []string{"install", "--immutable"},
+			Dir:    "/some/project",
+			Env:    []string{"SOME_VAR=some-value"},
+			Stdout: buffer,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(node.ExecuteCall.Receives.Execution.Args).To(Equal([]string{
+			"/some/project/.yarn/releases/yarn-4.0.2.cjs",
+			"install",
+			"--immutable",
+		}))
+		Expect(node.ExecuteCall.Receives.Execution.Dir).To(Equal("/some/project"))
+		Expect(node.ExecuteCall.Receives.Execution.Env).To(Equal([]string{"SOME_VAR=some-value"}))
+		Expect(node.ExecuteCall.Receives.Execution.Stdout).To(Equal(buffer))
+	})
+
+	context("when node fails", func() {
+		it.Before(func() {
+			node.ExecuteCall.Returns.Error = errors.New("some-error")
+		})
+
+		it("returns the error", func() {
+			err := executable.Execute(pexec.Execution{Args: []string{"install"}})
+			Expect(err).To(MatchError("some-error"))
+		})
+	})
+}
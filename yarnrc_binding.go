@@ -0,0 +1,94 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergeYarnrcYml merges a binding's .yarnrc.yml into the one in homeDir.
+func MergeYarnrcYml(bindingPath, homeDir string) (func() error, error) {
+	content, err := os.ReadFile(bindingPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s binding: %w", YarnrcYml, err)
+	}
+
+	binding := map[string]interface{}{}
+	err = yaml.Unmarshal(content, &binding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s binding: %w", YarnrcYml, err)
+	}
+
+	path := filepath.Join(homeDir, YarnrcYml)
+
+	restore := func() error {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove ~/%s: %w", YarnrcYml, err)
+		}
+		return nil
+	}
+
+	config := map[string]interface{}{}
+	previous, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read ~/%s: %w", YarnrcYml, err)
+	}
+
+	if err == nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = yaml.Unmarshal(previous, &config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ~/%s: %w", YarnrcYml, err)
+		}
+
+		restore = func() error {
+			err := os.WriteFile(path, previous, info.Mode().Perm())
+			if err == nil {
+				err = os.Chmod(path, info.Mode().Perm())
+			}
+			if err != nil {
+				return fmt.Errorf("failed to restore ~/%s: %w", YarnrcYml, err)
+			}
+			return nil
+		}
+	}
+
+	content, err = yaml.Marshal(mergeYarnrc(config, binding))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ~/%s: %w", YarnrcYml, err)
+	}
+
+	// The binding usually holds credentials
+	err = os.WriteFile(path, content, 0600)
+	if err == nil {
+		err = os.Chmod(path, 0600)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write ~/%s: %w", YarnrcYml, err)
+	}
+
+	return restore, nil
+}
+
+func mergeYarnrc(config, overrides map[string]interface{}) map[string]interface{} {
+	for key, value := range overrides {
+		existing, isMap := config[key].(map[string]interface{})
+		override, overridesMap := value.(map[string]interface{})
+		if isMap && overridesMap {
+			config[key] = mergeYarnrc(existing, override)
+			continue
+		}
+
+		config[key] = value
+	}
+
+	return config
+}
@@ -0,0 +1,124 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testYarnrcBinding(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		bindingDir string
+		homeDir    string
+	)
+
+	it.Before(func() {
+		var err error
+		bindingDir, err = os.MkdirTemp("", "binding")
+		Expect(err).NotTo(HaveOccurred())
+
+		homeDir, err = os.MkdirTemp("", "home")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(bindingDir, ".yarnrc.yml"), []byte(`npmRegistryServer: https://registry.example.com
+npmScopes:
+  private:
+    npmAuthToken: some-token
+`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(bindingDir)).To(Succeed())
+		Expect(os.RemoveAll(homeDir)).To(Succeed())
+	})
+
+	context("MergeYarnrcYml", func() {
+		it("writes the binding to ~/.yarnrc.yml and removes it on restore", func() {
+			restore, err := yarninstall.MergeYarnrcYml(filepath.Join(bindingDir, ".yarnrc.yml"), homeDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := os.ReadFile(filepath.Join(homeDir, ".yarnrc.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchYAML(`npmRegistryServer: https://registry.example.com
+npmScopes:
+  private:
+    npmAuthToken: some-token
+`))
+
+			info, err := os.Stat(filepath.Join(homeDir, ".yarnrc.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			Expect(restore()).To(Succeed())
+			Expect(filepath.Join(homeDir, ".yarnrc.yml")).NotTo(BeAnExistingFile())
+		})
+
+		context("when ~/.yarnrc.yml already exists", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(homeDir, ".yarnrc.yml"), []byte(`enableTelemetry: false
+npmRegistryServer: https://registry.yarnpkg.com
+npmScopes:
+  public:
+    npmRegistryServer: https://public.example.com
+  private:
+    npmAlwaysAuth: true
+`), 0644)).To(Succeed())
+			})
+
+			it("merges the binding into it and restores it", func() {
+				restore, err := yarninstall.MergeYarnrcYml(filepath.Join(bindingDir, ".yarnrc.yml"), homeDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(homeDir, ".yarnrc.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchYAML(`enableTelemetry: false
+npmRegistryServer: https://registry.example.com
+npmScopes:
+  public:
+    npmRegistryServer: https://public.example.com
+  private:
+    npmAlwaysAuth: true
+    npmAuthToken: some-token
+`))
+
+				Expect(restore()).To(Succeed())
+
+				content, err = os.ReadFile(filepath.Join(homeDir, ".yarnrc.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring("npmRegistryServer: https://registry.yarnpkg.com"))
+				Expect(string(content)).NotTo(ContainSubstring("some-token"))
+
+				info, err := os.Stat(filepath.Join(homeDir, ".yarnrc.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0644)))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the binding cannot be read", func() {
+				it("returns an error", func() {
+					_, err := yarninstall.MergeYarnrcYml(filepath.Join(bindingDir, "missing"), homeDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to read .yarnrc.yml binding")))
+				})
+			})
+
+			context("when ~/.yarnrc.yml is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(homeDir, ".yarnrc.yml"), []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.MergeYarnrcYml(filepath.Join(bindingDir, ".yarnrc.yml"), homeDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse ~/.yarnrc.yml")))
+				})
+			})
+		})
+	})
+}
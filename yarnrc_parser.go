@@ -1,9 +1,13 @@
 package yarninstall
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/paketo-buildpacks/yarn-install/lockfile"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,8 +17,10 @@ type YarnrcConfig struct {
 	PnpIgnorePatterns       []string               `yaml:"pnpIgnorePatterns"`
 	CacheFolder             string                 `yaml:"cacheFolder"`
 	EnableImmutableInstalls *bool                  `yaml:"enableImmutableInstalls"`
+	EnableGlobalCache       *bool                  `yaml:"enableGlobalCache"`
 	YarnPath                string                 `yaml:"yarnPath"`
 	PackageExtensions       map[string]interface{} `yaml:"packageExtensions"`
+	NpmRegistryServer       string                 `yaml:"npmRegistryServer"`
 }
 
 // ParseYarnrcYml parses a .yarnrc.yml file and returns the configuration
@@ -37,46 +43,211 @@ func ParseYarnrcYml(projectPath string) (*YarnrcConfig, error) {
 	return &config, nil
 }
 
+// Sources of the signals that are used to determine the Yarn version
+const (
+	YarnVersionSourcePackageManager = "packageManager field"
+	YarnVersionSourceYarnPath       = "yarnPath release"
+	YarnVersionSourceLockfile       = "yarn.lock format"
+	YarnVersionSourceYarnrcYml      = ".yarnrc.yml"
+	YarnVersionSourceDefault        = "default"
+)
+
+// YarnVersionSignal is a single piece of evidence about which Yarn version a
+// project uses
+type YarnVersionSignal struct {
+	Source  string
+	Version string
+}
+
+// YarnVersionResolution is the Yarn version selected from the signals found.
+type YarnVersionResolution struct {
+	Version   string
+	Source    string
+	Conflicts []YarnVersionSignal
+}
+
 // DetermineYarnVersion determines if the project uses Yarn Classic or Berry
 func DetermineYarnVersion(projectPath string) (string, error) {
-	// Check for .yarnrc.yml (Berry)
-	yarnrcYmlPath := filepath.Join(projectPath, YarnrcYml)
-	if _, err := os.Stat(yarnrcYmlPath); err == nil {
-		return YarnBerry, nil
+	resolution, err := ResolveYarnVersion(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	return resolution.Version, nil
+}
+
+// ResolveYarnVersion determines if the project uses Yarn Classic or Berry
+func ResolveYarnVersion(projectPath string) (YarnVersionResolution, error) {
+	var signals []YarnVersionSignal
+
+	manifest, err := ParsePackageManifest(projectPath)
+	if err != nil {
+		return YarnVersionResolution{}, err
+	}
+
+	if version := yarnGeneration(manifest.YarnVersion()); version != "" {
+		signals = append(signals, YarnVersionSignal{Source: YarnVersionSourcePackageManager, Version: version})
 	}
 
-	// Check for yarn.lock (could be either, but default to Classic if no .yarnrc.yml)
-	yarnLockPath := filepath.Join(projectPath, YarnLock)
-	if _, err := os.Stat(yarnLockPath); err == nil {
-		return YarnClassic, nil
+	config, err := ParseYarnrcYml(projectPath)
+	if err != nil {
+		return YarnVersionResolution{}, err
 	}
 
-	return "", nil
+	if config != nil && config.YarnPath != "" {
+		version := yarnGeneration(yarnReleaseVersion(config.YarnPath))
+		if version == "" {
+			// yarnPath is a Berry setting, so a release that does not carry
+			// a version in its name is assumed to be Berry
+			version = YarnBerry
+		}
+		signals = append(signals, YarnVersionSignal{Source: YarnVersionSourceYarnPath, Version: version})
+	}
+
+	yarnLock, err := os.Open(filepath.Join(projectPath, YarnLock))
+	if err != nil && !os.IsNotExist(err) {
+		return YarnVersionResolution{}, err
+	}
+
+	hasYarnLock := err == nil
+	if hasYarnLock {
+		format := lockfile.DetectFormat(yarnLock)
+		yarnLock.Close()
+
+		switch format {
+		case lockfile.FormatBerry:
+			signals = append(signals, YarnVersionSignal{Source: YarnVersionSourceLockfile, Version: YarnBerry})
+		case lockfile.FormatClassic:
+			signals = append(signals, YarnVersionSignal{Source: YarnVersionSourceLockfile, Version: YarnClassic})
+		}
+	}
+
+	if config != nil {
+		signals = append(signals, YarnVersionSignal{Source: YarnVersionSourceYarnrcYml, Version: YarnBerry})
+	}
+
+	if len(signals) == 0 {
+		if hasYarnLock {
+			return YarnVersionResolution{Version: YarnClassic, Source: YarnVersionSourceDefault}, nil
+		}
+
+		return YarnVersionResolution{}, nil
+	}
+
+	resolution := YarnVersionResolution{
+		Version: signals[0].Version,
+		Source:  signals[0].Source,
+	}
+
+	for _, signal := range signals[1:] {
+		if signal.Version != resolution.Version {
+			resolution.Conflicts = append(resolution.Conflicts, signal)
+		}
+	}
+
+	return resolution, nil
+}
+
+// yarnReleaseVersion extracts the version from the name of a Yarn release
+// such as .yarn/releases/yarn-3.6.0.cjs
+func yarnReleaseVersion(path string) string {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, "yarn-") {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "yarn-"), ".cjs"), ".js")
+}
+
+// yarnGeneration maps a Yarn version to Classic or Berry.
+func yarnGeneration(version string) string {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+
+	n, err := strconv.Atoi(major)
+	switch {
+	case err != nil || n < 1:
+		return ""
+	case n == 1:
+		return YarnClassic
+	default:
+		return YarnBerry
+	}
 }
 
 // DetermineProvisionType determines whether to provide node_modules or yarn_pkgs
-func DetermineProvisionType(projectPath string, config *YarnrcConfig) string {
-	// If no .yarnrc.yml, default to node_modules (Classic behavior)
-	if config == nil {
-		return PlanDependencyNodeModules
+func DetermineProvisionType(projectPath string, config *YarnrcConfig) (string, error) {
+	// A .yarnrc.yml implies Berry unless the project carries a stronger
+	// signal, such as a Classic lockfile next to a stray .yarnrc.yml
+	version := YarnClassic
+	if config != nil {
+		version = YarnBerry
+	}
+
+	resolution, err := ResolveYarnVersion(projectPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve yarn version: %w", err)
+	}
+
+	if resolution.Version != "" {
+		version = resolution.Version
+	}
+
+	if version != YarnBerry {
+		return PlanDependencyNodeModules, nil
+	}
+
+	var nodeLinker string
+	if config != nil {
+		nodeLinker = config.NodeLinker
 	}
 
 	// Check nodeLinker setting
-	switch config.NodeLinker {
+	switch nodeLinker {
 	case NodeLinkerNodeModules, NodeLinkerPnpm:
-		return PlanDependencyNodeModules
+		return PlanDependencyNodeModules, nil
 	case NodeLinkerPnP, "":
 		// PnP is the default for Berry when nodeLinker is not specified
-		return PlanDependencyYarnPkgs
+		return PlanDependencyYarnPkgs, nil
 	default:
 		// Unknown linker, default to node_modules for safety
-		return PlanDependencyNodeModules
+		return PlanDependencyNodeModules, nil
 	}
 }
 
+// FindYarnRelease returns the Yarn release that yarnPath points to.
+func FindYarnRelease(projectPath string, config *YarnrcConfig) (string, error) {
+	if config == nil || config.YarnPath == "" {
+		return "", nil
+	}
+
+	releasePath := config.YarnPath
+	if !filepath.IsAbs(releasePath) {
+		releasePath = filepath.Join(projectPath, releasePath)
+	}
+
+	info, err := os.Stat(releasePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if info.IsDir() {
+		return "", nil
+	}
+
+	return releasePath, nil
+}
+
 // ShouldUseNodeModules determines if the project should use node_modules
-func ShouldUseNodeModules(projectPath string, config *YarnrcConfig) bool {
-	return DetermineProvisionType(projectPath, config) == PlanDependencyNodeModules
+func ShouldUseNodeModules(projectPath string, config *YarnrcConfig) (bool, error) {
+	provisionType, err := DetermineProvisionType(projectPath, config)
+	if err != nil {
+		return false, err
+	}
+
+	return provisionType == PlanDependencyNodeModules, nil
 }
 
 // HasPnpFiles checks if PnP files exist in the project
@@ -92,6 +263,38 @@ func HasPnpFiles(projectPath string) (bool, error) {
 	return false, err
 }
 
+// PnPNodeOptions returns the NODE_OPTIONS that enable the PnP runtime.
+func PnPNodeOptions(projectPath string) (string, error) {
+	hasPnpFiles, err := HasPnpFiles(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !hasPnpFiles {
+		return "", nil
+	}
+
+	options := []string{"--require", filepath.Join(projectPath, PnpCjs)}
+
+	manifest, err := ParsePackageManifest(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	if manifest.Type == "module" {
+		_, err = os.Stat(filepath.Join(projectPath, PnpLoaderMjs))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if err == nil {
+			options = append(options, "--loader", filepath.Join(projectPath, PnpLoaderMjs))
+		}
+	}
+
+	return strings.Join(options, " "), nil
+}
+
 // HasYarnCache checks if .yarn/cache directory exists
 func HasYarnCache(projectPath string, config *YarnrcConfig) (bool, error) {
 	cacheDir := ".yarn/cache"
@@ -1,6 +1,7 @@
 package yarninstall_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -95,10 +96,119 @@ packageExtensions:
 		})
 	})
 
+	context("ResolveYarnVersion", func() {
+		context("when a Berry lockfile exists without a .yarnrc.yml", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "yarn.lock"), []byte("__metadata:\n  version: 8\n"), 0644)).To(Succeed())
+			})
+
+			it("returns Berry from the lockfile format", func() {
+				resolution, err := yarninstall.ResolveYarnVersion(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resolution).To(Equal(yarninstall.YarnVersionResolution{
+					Version: yarninstall.YarnBerry,
+					Source:  yarninstall.YarnVersionSourceLockfile,
+				}))
+			})
+		})
+
+		context("when a Classic lockfile exists next to a stray .yarnrc.yml", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "yarn.lock"), []byte("# yarn lockfile v1\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(tmpDir, ".yarnrc.yml"), []byte("enableTelemetry: false\n"), 0644)).To(Succeed())
+			})
+
+			it("returns Classic and reports the conflict", func() {
+				resolution, err := yarninstall.ResolveYarnVersion(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resolution).To(Equal(yarninstall.YarnVersionResolution{
+					Version: yarninstall.YarnClassic,
+					Source:  yarninstall.YarnVersionSourceLockfile,
+					Conflicts: []yarninstall.YarnVersionSignal{
+						{Source: yarninstall.YarnVersionSourceYarnrcYml, Version: yarninstall.YarnBerry},
+					},
+				}))
+			})
+		})
+
+		context("when the packageManager field pins Yarn", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte(`{"packageManager": "yarn@4.0.2+sha224.abc"}`), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(tmpDir, "yarn.lock"), []byte("# yarn lockfile v1\n"), 0644)).To(Succeed())
+			})
+
+			it("takes precedence over the lockfile format", func() {
+				resolution, err := yarninstall.ResolveYarnVersion(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resolution.Version).To(Equal(yarninstall.YarnBerry))
+				Expect(resolution.Source).To(Equal(yarninstall.YarnVersionSourcePackageManager))
+				Expect(resolution.Conflicts).To(Equal([]yarninstall.YarnVersionSignal{
+					{Source: yarninstall.YarnVersionSourceLockfile, Version: yarninstall.YarnClassic},
+				}))
+			})
+		})
+
+		context("when yarnPath points to a versioned release", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, ".yarnrc.yml"), []byte("yarnPath: .yarn/releases/yarn-1.22.19.cjs\n"), 0644)).To(Succeed())
+			})
+
+			it("uses the version of the release", func() {
+				resolution, err := yarninstall.ResolveYarnVersion(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resolution.Version).To(Equal(yarninstall.YarnClassic))
+				Expect(resolution.Source).To(Equal(yarninstall.YarnVersionSourceYarnPath))
+				Expect(resolution.Conflicts).To(Equal([]yarninstall.YarnVersionSignal{
+					{Source: yarninstall.YarnVersionSourceYarnrcYml, Version: yarninstall.YarnBerry},
+				}))
+			})
+		})
+
+		context("when the lockfile has no recognisable header", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "yarn.lock"), []byte(""), 0644)).To(Succeed())
+			})
+
+			it("defaults to Classic", func() {
+				resolution, err := yarninstall.ResolveYarnVersion(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resolution).To(Equal(yarninstall.YarnVersionResolution{
+					Version: yarninstall.YarnClassic,
+					Source:  yarninstall.YarnVersionSourceDefault,
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the package.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte("%%%"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.ResolveYarnVersion(tmpDir)
+					Expect(err).To(MatchError(ContainSubstring("unable to decode package.json")))
+				})
+			})
+
+			context("when the .yarnrc.yml is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(tmpDir, ".yarnrc.yml"), []byte("nodeLinker: ["), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.ResolveYarnVersion(tmpDir)
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+
 	context("DetermineProvisionType", func() {
 		context("when config is nil (Classic)", func() {
 			it("returns node_modules", func() {
-				provisionType := yarninstall.DetermineProvisionType(tmpDir, nil)
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, nil)
+				Expect(err).NotTo(HaveOccurred())
 				Expect(provisionType).To(Equal(yarninstall.PlanDependencyNodeModules))
 			})
 		})
@@ -108,7 +218,8 @@ packageExtensions:
 				config := &yarninstall.YarnrcConfig{
 					NodeLinker: "node-modules",
 				}
-				provisionType := yarninstall.DetermineProvisionType(tmpDir, config)
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, config)
+				Expect(err).NotTo(HaveOccurred())
 				Expect(provisionType).To(Equal(yarninstall.PlanDependencyNodeModules))
 			})
 		})
@@ -118,7 +229,8 @@ packageExtensions:
 				config := &yarninstall.YarnrcConfig{
 					NodeLinker: "pnpm",
 				}
-				provisionType := yarninstall.DetermineProvisionType(tmpDir, config)
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, config)
+				Expect(err).NotTo(HaveOccurred())
 				Expect(provisionType).To(Equal(yarninstall.PlanDependencyNodeModules))
 			})
 		})
@@ -128,7 +240,28 @@ packageExtensions:
 				config := &yarninstall.YarnrcConfig{
 					NodeLinker: "pnp",
 				}
-				provisionType := yarninstall.DetermineProvisionType(tmpDir, config)
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(provisionType).To(Equal(yarninstall.PlanDependencyYarnPkgs))
+			})
+		})
+
+		context("when a Classic lockfile outweighs the .yarnrc.yml", func() {
+			it("returns node_modules", func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "yarn.lock"), []byte("# yarn lockfile v1\n"), 0644)).To(Succeed())
+
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, &yarninstall.YarnrcConfig{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(provisionType).To(Equal(yarninstall.PlanDependencyNodeModules))
+			})
+		})
+
+		context("when a Berry lockfile exists without a .yarnrc.yml", func() {
+			it("returns yarn_pkgs", func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "yarn.lock"), []byte("__metadata:\n  version: 8\n"), 0644)).To(Succeed())
+
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, nil)
+				Expect(err).NotTo(HaveOccurred())
 				Expect(provisionType).To(Equal(yarninstall.PlanDependencyYarnPkgs))
 			})
 		})
@@ -136,10 +269,55 @@ packageExtensions:
 		context("when nodeLinker is not specified (defaults to pnp for Berry)", func() {
 			it("returns yarn_pkgs", func() {
 				config := &yarninstall.YarnrcConfig{}
-				provisionType := yarninstall.DetermineProvisionType(tmpDir, config)
+				provisionType, err := yarninstall.DetermineProvisionType(tmpDir, config)
+				Expect(err).NotTo(HaveOccurred())
 				Expect(provisionType).To(Equal(yarninstall.PlanDependencyYarnPkgs))
 			})
 		})
+
+		context("when the yarn version cannot be resolved", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte("%%%"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := yarninstall.DetermineProvisionType(tmpDir, &yarninstall.YarnrcConfig{})
+				Expect(err).To(MatchError(ContainSubstring("failed to resolve yarn version")))
+			})
+		})
+	})
+
+	context("FindYarnRelease", func() {
+		context("when yarnPath points to a release in the project", func() {
+			it("returns the absolute path of the release", func() {
+				Expect(os.MkdirAll(filepath.Join(tmpDir, ".yarn", "releases"), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(tmpDir, ".yarn", "releases", "yarn-4.0.2.cjs"), []byte{}, 0644)).To(Succeed())
+
+				release, err := yarninstall.FindYarnRelease(tmpDir, &yarninstall.YarnrcConfig{
+					YarnPath: ".yarn/releases/yarn-4.0.2.cjs",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release).To(Equal(filepath.Join(tmpDir, ".yarn", "releases", "yarn-4.0.2.cjs")))
+			})
+		})
+
+		context("when the release is missing", func() {
+			it("returns an empty string", func() {
+				release, err := yarninstall.FindYarnRelease(tmpDir, &yarninstall.YarnrcConfig{
+					YarnPath: ".yarn/releases/yarn-4.0.2.cjs",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release).To(BeEmpty())
+			})
+		})
+
+		context("when yarnPath is not set", func() {
+			it("returns an empty string", func() {
+				release, err := yarninstall.FindYarnRelease(tmpDir, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release).To(BeEmpty())
+			})
+		})
 	})
 
 	context("HasPnpFiles", func() {
@@ -164,6 +342,44 @@ packageExtensions:
 		})
 	})
 
+	context("PnPNodeOptions", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte(`{}`), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmpDir, ".pnp.cjs"), []byte{}, 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmpDir, ".pnp.loader.mjs"), []byte{}, 0644)).To(Succeed())
+		})
+
+		it("requires the PnP runtime", func() {
+			options, err := yarninstall.PnPNodeOptions(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(options).To(Equal(fmt.Sprintf("--require %s", filepath.Join(tmpDir, ".pnp.cjs"))))
+		})
+
+		context("when the project is an ES module", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte(`{"type": "module"}`), 0644)).To(Succeed())
+			})
+
+			it("also registers the PnP loader", func() {
+				options, err := yarninstall.PnPNodeOptions(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(options).To(Equal(fmt.Sprintf("--require %s --loader %s", filepath.Join(tmpDir, ".pnp.cjs"), filepath.Join(tmpDir, ".pnp.loader.mjs"))))
+			})
+		})
+
+		context("when there is no PnP runtime", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(tmpDir, ".pnp.cjs"))).To(Succeed())
+			})
+
+			it("returns no options", func() {
+				options, err := yarninstall.PnPNodeOptions(tmpDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(options).To(BeEmpty())
+			})
+		})
+	})
+
 	context("HasYarnCache", func() {
 		context("when .yarn/cache exists", func() {
 			it("returns true", func() {